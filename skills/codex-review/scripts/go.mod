@@ -1,5 +1,9 @@
 module codex-review
 
-go 1.21
+go 1.22
 
-require golang.org/x/sys v0.28.0
+require codexkit v0.0.0
+
+require golang.org/x/sys v0.28.0 // indirect
+
+replace codexkit => ../../codexkit
@@ -1,230 +1,142 @@
 package main
 
 import (
+	"cmp"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"regexp"
-	"sort"
-	"strconv"
 	"strings"
-)
-
-const (
-	defaultMaxResults   = 200
-	defaultMaxReadLines = 400
-	defaultMaxIters     = 50
-	maxGrepFileSize     = 2 * 1024 * 1024 // 2MB
-)
 
-var (
-	// Security patterns
-	denyBasenamesRE = regexp.MustCompile(`(^\.env$|^\.env\..+|^id_rsa$|^id_rsa\..+|^known_hosts$|^config$|^credentials$|^\.npmrc$|^\.pypirc$|^\.netrc$|^secrets$|^secrets\..+)`)
-	denyExtRE       = regexp.MustCompile(`(?i)(\.pem$|\.key$|\.p12$|\.pfx$|\.cer$|\.crt$|\.der$|\.kdbx$|\.tfstate$|\.tfvars$)`)
-	denyPathRE      = regexp.MustCompile(`(^|/)\.git(/|$)|\.docker/config\.json$`)
-	safeSessionRE   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+	"codexkit/agent"
+	"codexkit/api"
+	"codexkit/fstools"
+	"codexkit/session"
 )
 
-// ToolResult represents the result of a tool execution
-type ToolResult struct {
-	OK      bool                   `json:"ok"`
-	Tool    string                 `json:"tool,omitempty"`
-	Error   string                 `json:"error,omitempty"`
-	Results interface{}            `json:"results,omitempty"`
-	Content string                 `json:"content,omitempty"`
-	Count   int                    `json:"count,omitempty"`
-	Path    string                 `json:"path,omitempty"`
-	Extra   map[string]interface{} `json:",inline"`
-}
-
-// SessionData stores conversation state
-type SessionData struct {
-	ConversationID string `json:"conversation_id"`
+func main() {
+	os.Exit(run(os.Args, os.Stdout, os.Stderr))
 }
 
-func main() {
-	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, `Usage: codex-review "<session-name>" "<review-prompt>"`)
-		os.Exit(2)
+// run executes the CLI and returns the process exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) >= 2 && (args[1] == "sessions" || args[1] == "transcript") {
+		return runSessions(args[1:], stdout, stderr)
+	}
+	if len(args) < 3 {
+		fmt.Fprintln(stderr, `Usage: codex-review "<session-name>" "<review-prompt>"`)
+		fmt.Fprintln(stderr, `       codex-review sessions list|show|rm|prune ... | transcript show <name>`)
+		return 2
 	}
 
-	sessionName := os.Args[1]
-	reviewPrompt := strings.Join(os.Args[2:], " ")
+	sessionName := args[1]
+	reviewPrompt := strings.Join(args[2:], " ")
 
 	// Validate session name
-	if !safeSessionRE.MatchString(sessionName) {
-		fmt.Fprintln(os.Stderr, "Invalid session name: use A-Za-z0-9._- only, max 64 chars, must start with alphanumeric")
-		os.Exit(2)
-	}
-
-	// Environment
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		fmt.Fprintln(os.Stderr, "OPENAI_API_KEY is required")
-		os.Exit(2)
+	if !session.ValidName(sessionName) {
+		fmt.Fprintln(stderr, "Invalid session name: use A-Za-z0-9._- only, max 64 chars, must start with alphanumeric")
+		return 2
 	}
 
-	model := getEnv("OPENAI_MODEL", "gpt-5.2-codex")
-	reasoningEffort := getEnv("REASONING_EFFORT", "high") // Higher for code review
-	maxIters := getEnvInt("MAX_ITERS", defaultMaxIters)
-
 	// Detect repo root
-	repoRoot, err := detectRepoRoot()
+	repoRoot, err := agent.DetectRepoRoot()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to detect repo root: %v\n", err)
-		os.Exit(2)
-	}
-
-	// Session management
-	sessionsDir := getEnv("STATE_DIR", filepath.Join(repoRoot, ".codex-sessions"))
-	if err := os.MkdirAll(sessionsDir, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create sessions dir: %v\n", err)
-		os.Exit(2)
+		fmt.Fprintf(stderr, "Failed to detect repo root: %v\n", err)
+		return 2
 	}
 
-	sessionFile := filepath.Join(sessionsDir, sessionName+".json")
-
 	// Load project memory (CLAUDE.md + rules) like Claude Code
-	projectMemory := loadProjectMemory(repoRoot)
-
-	// Load or create conversation
-	conversationID, err := loadSession(sessionFile)
-	if err != nil || conversationID == "" {
-		systemPrompt := buildSystemPrompt(repoRoot, sessionName, projectMemory)
-		conversationID, err = createConversation(apiKey, systemPrompt)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create conversation: %v\n", err)
-			os.Exit(3)
-		}
-		if err := saveSession(sessionFile, conversationID); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to save session: %v\n", err)
-		}
-	}
-
-	// Execute review with tool loop
-	if err := executeReview(apiKey, model, reasoningEffort, conversationID, reviewPrompt, repoRoot, maxIters); err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		os.Exit(3)
-	}
+	projectMemory := agent.LoadProjectMemory(repoRoot)
+
+	// SIGINT/SIGTERM cancel the in-flight request; the session is saved first
+	ctx, stop := agent.SignalContext()
+	defer stop()
+
+	// Execute review with tool loop (READ-ONLY tools)
+	_, code := agent.RunSession(ctx, agent.SessionSpec{
+		Name:     sessionName,
+		Kind:     "review",
+		Dir:      sessionsDirFor(repoRoot),
+		RepoRoot: repoRoot,
+		Prompt:   reviewPrompt,
+		SystemPrompt: func() string {
+			return buildSystemPrompt(repoRoot, sessionName, projectMemory)
+		},
+		Tools:           fstools.ReadOnlyTools(),
+		ReasoningEffort: "high", // Higher for code review
+		Stdout:          stdout,
+		Stderr:          stderr,
+	})
+	return code
 }
 
-// Helper functions
-func getEnv(key, defaultVal string) string {
-	if val := os.Getenv(key); val != "" {
-		return val
-	}
-	return defaultVal
+// sessionsDirFor returns where sessions are stored (STATE_DIR overrides)
+func sessionsDirFor(repoRoot string) string {
+	return agent.GetEnv("STATE_DIR", filepath.Join(repoRoot, ".codex-sessions"))
 }
 
-func getEnvInt(key string, defaultVal int) int {
-	if val := os.Getenv(key); val != "" {
-		if i, err := strconv.Atoi(val); err == nil {
-			return i
-		}
-	}
-	return defaultVal
-}
-
-func detectRepoRoot() (string, error) {
-	if root := os.Getenv("REPO_ROOT"); root != "" {
-		return filepath.Abs(root)
-	}
-
-	// Walk up to find .git directory
-	cwd, err := os.Getwd()
+// runSessions handles the "sessions" and "transcript" subcommands
+func runSessions(args []string, stdout, stderr io.Writer) int {
+	repoRoot, err := agent.DetectRepoRoot()
 	if err != nil {
-		return "", err
-	}
-
-	dir := cwd
-	for {
-		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
-			return dir, nil
-		}
-		parent := filepath.Dir(dir)
-		if parent == dir {
-			break
-		}
-		dir = parent
-	}
-
-	// No git found, use cwd
-	return cwd, nil
+		fmt.Fprintf(stderr, "Failed to detect repo root: %v\n", err)
+		return 2
+	}
+
+	ctx, stop := agent.SignalContext()
+	defer stop()
+
+	return session.Command{
+		Prog: "codex-review",
+		Dir:  sessionsDirFor(repoRoot),
+		Connect: func() (api.Provider, error) {
+			return agent.NewProviderFromEnv(stderr)
+		},
+		SystemPrompt: func(name string, d session.Data) string {
+			root := cmp.Or(d.RepoRoot, repoRoot)
+			return buildSystemPrompt(root, name, agent.LoadProjectMemory(root))
+		},
+		Stdout: stdout,
+		Stderr: stderr,
+	}.Run(ctx, args)
 }
 
-// loadProjectMemory loads CLAUDE.md and rules like Claude Code
-// Priority: user memory -> user rules -> project memory -> project rules
-func loadProjectMemory(repoRoot string) string {
-	var sections []string
-	homeDir, _ := os.UserHomeDir()
-
-	// 1. User memory: ~/.claude/CLAUDE.md
-	if homeDir != "" {
-		userClaudePath := filepath.Join(homeDir, ".claude", "CLAUDE.md")
-		if data, err := os.ReadFile(userClaudePath); err == nil {
-			sections = append(sections, fmt.Sprintf("### %s (user memory)\n\n%s", userClaudePath, string(data)))
-		}
-
-		// 2. User rules: ~/.claude/rules/*.md
-		userRulesDir := filepath.Join(homeDir, ".claude", "rules")
-		if rules := loadRulesDir(userRulesDir, "user rules"); len(rules) > 0 {
-			sections = append(sections, rules...)
-		}
+// buildSystemPrompt loads system-prompt-en.md and substitutes variables
+func buildSystemPrompt(repoRoot, sessionName, projectMemory string) string {
+	prompt, err := agent.LoadPrompt("system-prompt-en.md", map[string]string{
+		"repo_root":      repoRoot,
+		"session_name":   sessionName,
+		"project_memory": projectMemory,
+	})
+	if err == nil {
+		return prompt
 	}
 
-	// 3. Project memory: .claude/CLAUDE.md or CLAUDE.md
-	projectClaudePaths := []string{
-		filepath.Join(repoRoot, ".claude", "CLAUDE.md"),
-		filepath.Join(repoRoot, "CLAUDE.md"),
-	}
-	for _, p := range projectClaudePaths {
-		if data, err := os.ReadFile(p); err == nil {
-			relPath, _ := filepath.Rel(repoRoot, p)
-			if relPath == "" {
-				relPath = p
-			}
-			sections = append(sections, fmt.Sprintf("### %s (project memory)\n\n%s", relPath, string(data)))
-			break // Only first found
-		}
-	}
+	// Fallback inline prompt
+	return fmt.Sprintf(`# Code Review Expert - GPT-5.2-Codex
 
-	// 4. Project rules: .claude/rules/*.md
-	projectRulesDir := filepath.Join(repoRoot, ".claude", "rules")
-	if rules := loadRulesDir(projectRulesDir, "project rules"); len(rules) > 0 {
-		sections = append(sections, rules...)
-	}
+You are a professional code reviewer with extensive experience.
 
-	if len(sections) == 0 {
-		return ""
-	}
+Repository Root: %s
+Session: %s
 
-	return strings.Join(sections, "\n\n---\n\n")
-}
+## Project Guidelines
 
-// loadRulesDir loads all .md files from a rules directory
-func loadRulesDir(rulesDir, ruleType string) []string {
-	var rules []string
+%s
 
-	entries, err := os.ReadDir(rulesDir)
-	if err != nil {
-		return rules
-	}
+---
 
-	// Sort by filename (lower numbers = higher priority)
-	var mdFiles []string
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") {
-			mdFiles = append(mdFiles, entry.Name())
-		}
-	}
-	sort.Strings(mdFiles)
+**CRITICAL: You provide READ-ONLY analysis.** Identify issues and provide suggestions, but do NOT modify code.
 
-	for _, name := range mdFiles {
-		path := filepath.Join(rulesDir, name)
-		if data, err := os.ReadFile(path); err == nil {
-			rules = append(rules, fmt.Sprintf("### %s (%s)\n\n%s", name, ruleType, string(data)))
-		}
-	}
+Available Tools: Glob, Grep, Read
+
+Analyze code across 5 dimensions:
+- 🐛 Bugs (Critical)
+- 🔒 Security (High)
+- ⚡ Performance (Medium)
+- 📝 Code Quality (Low)
+- 🔧 Refactoring
 
-	return rules
+Provide detailed markdown reports with actionable suggestions.
+`, repoRoot, sessionName, projectMemory)
 }
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"codexkit/fakeapi"
+	"codexkit/session"
+)
+
+// setupReview points the binary at srv and a scratch repository
+func setupReview(t *testing.T, srv *fakeapi.Server) string {
+	t.Helper()
+	repoRoot := t.TempDir()
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("OPENAI_BASE_URL", srv.URL)
+	t.Setenv("REPO_ROOT", repoRoot)
+	t.Setenv("STATE_DIR", filepath.Join(repoRoot, ".codex-sessions"))
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("RETRY_MAX_ATTEMPTS", "1")
+	return repoRoot
+}
+
+func runReview(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"codex-review"}, args...), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestReviewIsReadOnly(t *testing.T) {
+	srv := fakeapi.New(
+		fakeapi.Calls(
+			fakeapi.ToolCall{CallID: "grep", Name: "Grep", Arguments: `{"query":"password"}`},
+			fakeapi.ToolCall{CallID: "write", Name: "Write", Arguments: `{"path":"auth.go","content":"pwned"}`},
+		),
+		fakeapi.Text("## Security\n- auth.go:1 hardcoded password\n"),
+	)
+	defer srv.Close()
+	repoRoot := setupReview(t, srv)
+	original := "package auth\nconst password = \"hunter2\"\n"
+	if err := os.WriteFile(filepath.Join(repoRoot, "auth.go"), []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runReview(t, "security-review", "Review", "auth.go")
+	if code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "hardcoded password") {
+		t.Errorf("stdout missing review text:\n%s", stdout)
+	}
+
+	outputs := srv.FunctionOutputs(1)
+	var grep, write map[string]interface{}
+	json.Unmarshal([]byte(outputs["grep"]), &grep)
+	json.Unmarshal([]byte(outputs["write"]), &write)
+	if grep["ok"] != true || grep["count"] != float64(1) {
+		t.Errorf("Grep output = %v", grep)
+	}
+	if write["ok"] != false || !strings.Contains(write["error"].(string), "Unknown tool: Write") {
+		t.Errorf("Write must be rejected, got %v", write)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(repoRoot, "auth.go"))
+	if string(data) != original {
+		t.Errorf("review modified the repository: %q", data)
+	}
+
+	// Review prompt is joined from all remaining arguments
+	first := srv.Requests()[0]
+	input := first["input"].([]interface{})[0].(map[string]interface{})
+	if input["content"] != "Review auth.go" {
+		t.Errorf("review prompt = %v", input["content"])
+	}
+	if first["parallel_tool_calls"] != false {
+		t.Errorf("review must disable parallel tool calls")
+	}
+}
+
+func TestReviewHTTPErrorExitCode(t *testing.T) {
+	srv := fakeapi.New(fakeapi.Error(401, `{"error":{"message":"bad key"}}`))
+	defer srv.Close()
+	setupReview(t, srv)
+
+	code, _, stderr := runReview(t, "auth-review", "Review auth.go")
+	if code != 3 || !strings.Contains(stderr, "HTTP 401") {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+}
+
+func TestReviewUsageErrors(t *testing.T) {
+	srv := fakeapi.New()
+	defer srv.Close()
+	setupReview(t, srv)
+
+	if code, _, _ := runReview(t, "only-session"); code != 2 {
+		t.Errorf("missing prompt: exit code %d, want 2", code)
+	}
+	if code, _, _ := runReview(t, "-bad", "prompt"); code != 2 {
+		t.Errorf("invalid session name: exit code %d, want 2", code)
+	}
+}
+
+func TestReviewSessionsSubcommands(t *testing.T) {
+	srv := fakeapi.New(fakeapi.Text("LGTM\n").WithUsage(100, 0, 20, 0))
+	defer srv.Close()
+	repoRoot := setupReview(t, srv)
+	sessionsDir := filepath.Join(repoRoot, ".codex-sessions")
+
+	if code, _, stderr := runReview(t, "fresh-review", "Review main.go"); code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	// A legacy session last touched long ago
+	stale := filepath.Join(sessionsDir, "stale-review.json")
+	os.WriteFile(stale, []byte(`{"conversation_id":"conv_fake_1"}`), 0644)
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	os.Chtimes(stale, old, old)
+	staleChain := filepath.Join(sessionsDir, "stale-chain.json")
+	os.WriteFile(staleChain, []byte(`{"conversation_id":"chain_0123"}`), 0644)
+	os.Chtimes(staleChain, old, old)
+
+	code, stdout, _ := runReview(t, "sessions", "list")
+	if code != 0 || !strings.Contains(stdout, "NAME") || !strings.Contains(stdout, "fresh-review  complete") || !strings.Contains(stdout, "stale-review") {
+		t.Errorf("list: exit code %d, stdout:\n%s", code, stdout)
+	}
+	if strings.Index(stdout, "fresh-review") > strings.Index(stdout, "stale-review") {
+		t.Errorf("list should show the most recently updated first:\n%s", stdout)
+	}
+
+	code, stdout, _ = runReview(t, "sessions", "show", "fresh-review", "--json")
+	var shown map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &shown); code != 0 || err != nil {
+		t.Fatalf("show --json: exit code %d, %v:\n%s", code, err, stdout)
+	}
+	if shown["name"] != "fresh-review" || shown["prompt"] != "Review main.go" || shown["status"] != "complete" {
+		t.Errorf("show --json = %v", shown)
+	}
+
+	if code, _, stderr := runReview(t, "sessions", "show", "missing"); code == 0 || !strings.Contains(stderr, "no such session") {
+		t.Errorf("show missing: exit code %d, stderr:\n%s", code, stderr)
+	}
+
+	code, stdout, _ = runReview(t, "sessions", "prune", "--older-than", "7d", "--dry-run")
+	if code != 0 || !strings.Contains(stdout, "Would remove stale-review") || strings.Contains(stdout, "fresh-review") {
+		t.Errorf("prune --dry-run: exit code %d, stdout:\n%s", code, stdout)
+	}
+	if _, err := os.Stat(stale); err != nil {
+		t.Fatal("dry run must not remove files")
+	}
+
+	code, stdout, _ = runReview(t, "sessions", "prune", "--older-than", "7d", "--remote")
+	if code != 0 || !strings.Contains(stdout, "Removed stale-review") || !strings.Contains(stdout, "Removed stale-chain") {
+		t.Errorf("prune: exit code %d, stdout:\n%s", code, stdout)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale session should be removed")
+	}
+	// Client-side chain IDs are never sent to the server
+	if deleted := srv.Deleted(); len(deleted) != 1 || deleted[0] != "conv_fake_1" {
+		t.Errorf("remote deletions = %v", deleted)
+	}
+
+	if code, _, _ := runReview(t, "sessions", "rm", "fresh-review"); code != 0 {
+		t.Errorf("rm: exit code %d", code)
+	}
+	if code, stdout, _ := runReview(t, "sessions", "list", "--json"); code != 0 || strings.TrimSpace(stdout) != "[]" {
+		t.Errorf("list --json after rm: exit code %d, stdout:\n%s", code, stdout)
+	}
+}
+
+func TestReviewSessionsFork(t *testing.T) {
+	srv := fakeapi.New(
+		fakeapi.Calls(fakeapi.ToolCall{CallID: "g", Name: "Glob", Arguments: `{"pattern":"*.go"}`}),
+		fakeapi.Text("Found two issues\n"),
+	)
+	defer srv.Close()
+	repoRoot := setupReview(t, srv)
+	sessionsDir := filepath.Join(repoRoot, ".codex-sessions")
+
+	if code, _, stderr := runReview(t, "base-review", "Review the code"); code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+
+	// Replay: a new conversation seeded with the base session's history
+	code, stdout, stderr := runReview(t, "sessions", "fork", "base-review", "branch-a")
+	if code != 0 || !strings.Contains(stdout, "Forked base-review -> branch-a (replayed transcript into conv_fake_2)") {
+		t.Fatalf("fork: exit code %d, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+	}
+	var roles []string
+	for _, item := range srv.Items("conv_fake_2") {
+		m := item.(map[string]interface{})
+		roles = append(roles, fmt.Sprint(m["role"], m["type"]))
+	}
+	if got := strings.Join(roles, " "); got != "developer<nil> user<nil> <nil>function_call <nil>function_call_output assistant<nil>" {
+		t.Errorf("replayed items = %s", got)
+	}
+
+	srv.Push(fakeapi.Text("Branch A answer\n"))
+	if code, _, stderr := runReview(t, "branch-a", "Dig into issue one"); code != 0 {
+		t.Fatalf("branch run: exit code %d, stderr:\n%s", code, stderr)
+	}
+	if req := srv.Requests()[2]; req["conversation"] != "conv_fake_2" {
+		t.Errorf("fork must use its own conversation, got %v", req["conversation"])
+	}
+
+	// previous_response_id: no new conversation, continues from the last response
+	code, stdout, _ = runReview(t, "sessions", "fork", "base-review", "branch-b", "--from-response")
+	if code != 0 || !strings.Contains(stdout, "continues from response resp_fake_2") {
+		t.Fatalf("fork --from-response: exit code %d, stdout:\n%s", code, stdout)
+	}
+	srv.Push(fakeapi.Text("Branch B answer\n"))
+	if code, _, stderr := runReview(t, "branch-b", "Dig into issue two"); code != 0 {
+		t.Fatalf("branch-b run: exit code %d, stderr:\n%s", code, stderr)
+	}
+	req := srv.Requests()[3]
+	if _, ok := req["conversation"]; ok || req["previous_response_id"] != "resp_fake_2" {
+		t.Errorf("branch-b request = conversation %v, previous_response_id %v", req["conversation"], req["previous_response_id"])
+	}
+	if convs := srv.Conversations(); len(convs) != 2 {
+		t.Errorf("conversations = %v", convs)
+	}
+
+	// The base session is untouched and forks record their origin
+	base, _ := session.Load(filepath.Join(sessionsDir, "base-review.json"))
+	fork, _ := session.Load(filepath.Join(sessionsDir, "branch-b.json"))
+	if base.ConversationID != "conv_fake_1" || base.Runs != 1 || fork.ForkedFrom != "base-review" || fork.LastResponseID != "resp_fake_4" ||
+		!strings.HasPrefix(fork.ConversationID, "chain_") {
+		t.Errorf("base = %+v\nfork = %+v", base, fork)
+	}
+
+	if code, _, stderr := runReview(t, "sessions", "fork", "base-review", "branch-a"); code == 0 || !strings.Contains(stderr, "already exists") {
+		t.Errorf("fork onto existing session: exit code %d, stderr:\n%s", code, stderr)
+	}
+}
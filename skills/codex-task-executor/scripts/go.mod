@@ -2,11 +2,8 @@ module codex-task-executor
 
 go 1.25.6
 
-require (
-	github.com/openai/openai-go/v3 v3.17.0 // indirect
-	github.com/tidwall/gjson v1.18.0 // indirect
-	github.com/tidwall/match v1.1.1 // indirect
-	github.com/tidwall/pretty v1.2.1 // indirect
-	github.com/tidwall/sjson v1.2.5 // indirect
-	golang.org/x/sys v0.40.0 // indirect
-)
+require codexkit v0.0.0
+
+require golang.org/x/sys v0.28.0 // indirect
+
+replace codexkit => ../../codexkit
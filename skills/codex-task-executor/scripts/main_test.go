@@ -0,0 +1,585 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"codexkit/fakeapi"
+	"codexkit/session"
+)
+
+// setupTask points the binary at srv and a scratch repository
+func setupTask(t *testing.T, srv *fakeapi.Server) (repoRoot, planFile string) {
+	t.Helper()
+	repoRoot = t.TempDir()
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("OPENAI_BASE_URL", srv.URL)
+	t.Setenv("REPO_ROOT", repoRoot)
+	t.Setenv("STATE_DIR", filepath.Join(repoRoot, ".codex-sessions", "tasks"))
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("RETRY_MAX_ATTEMPTS", "1")
+
+	planFile = filepath.Join(repoRoot, "plan.md")
+	if err := os.WriteFile(planFile, []byte("# Plan\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return repoRoot, planFile
+}
+
+func runTask(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"execute-task"}, args...), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func decodeResult(t *testing.T, raw string) map[string]interface{} {
+	t.Helper()
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		t.Fatalf("tool output is not JSON: %q", raw)
+	}
+	return result
+}
+
+func TestExecuteTaskToolLoop(t *testing.T) {
+	for _, stream := range []string{"true", "false"} {
+		t.Run("stream="+stream, func(t *testing.T) {
+			srv := fakeapi.New(
+				fakeapi.Calls(fakeapi.ToolCall{CallID: "w", Name: "Write", Arguments: `{"path":"src/a.txt","content":"hello world\n"}`}),
+				fakeapi.Calls(fakeapi.ToolCall{CallID: "e", Name: "Edit", Arguments: `{"path":"src/a.txt","old_string":"hello","new_string":"goodbye"}`}),
+				fakeapi.Calls(
+					fakeapi.ToolCall{CallID: "r", Name: "Read", Arguments: `{"path":"src/a.txt"}`},
+					fakeapi.ToolCall{CallID: "g", Name: "Grep", Arguments: `{"query":"goodbye"}`},
+				),
+				fakeapi.Calls(fakeapi.ToolCall{CallID: "bad", Name: "Read", Arguments: `{"path":`}),
+				fakeapi.Text("[PROGRESS] Updated greeting\n[FILES_MODIFIED]\n- src/a.txt (created)\n"),
+			)
+			defer srv.Close()
+			repoRoot, plan := setupTask(t, srv)
+			t.Setenv("OPENAI_STREAM", stream)
+
+			code, stdout, stderr := runTask(t, "task-1", "Write a greeting", plan)
+			if code != 0 {
+				t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+			}
+
+			if !strings.Contains(stdout, "[PROGRESS] Updated greeting") {
+				t.Errorf("stdout missing progress marker:\n%s", stdout)
+			}
+			if !strings.Contains(stdout, "[CODEX_COMPLETE] Task completed in 5 iterations") {
+				t.Errorf("stdout missing completion marker:\n%s", stdout)
+			}
+			if !strings.Contains(stderr, "[TOOL_CALL] Write(") {
+				t.Errorf("stderr missing tool trace:\n%s", stderr)
+			}
+
+			data, err := os.ReadFile(filepath.Join(repoRoot, "src", "a.txt"))
+			if err != nil || string(data) != "goodbye world\n" {
+				t.Errorf("file content = %q, %v", data, err)
+			}
+
+			// Parallel calls in one turn both get outputs in the next request
+			outputs := srv.FunctionOutputs(3)
+			if len(outputs) != 2 {
+				t.Fatalf("expected 2 outputs for parallel calls, got %v", outputs)
+			}
+			if r := decodeResult(t, outputs["r"]); r["ok"] != true || !strings.Contains(r["content"].(string), "goodbye world") {
+				t.Errorf("Read output = %v", r)
+			}
+			if g := decodeResult(t, outputs["g"]); g["ok"] != true || g["count"] != float64(1) {
+				t.Errorf("Grep output = %v", g)
+			}
+
+			// Malformed arguments are reported back to the model, not fatal
+			if bad := decodeResult(t, srv.FunctionOutputs(4)["bad"]); bad["ok"] != false || !strings.Contains(bad["error"].(string), "Invalid arguments") {
+				t.Errorf("malformed arguments output = %v", bad)
+			}
+
+			if convs := srv.Conversations(); len(convs) != 1 {
+				t.Errorf("conversations created = %v", convs)
+			}
+		})
+	}
+}
+
+func TestExecuteTaskResumesSession(t *testing.T) {
+	srv := fakeapi.New(fakeapi.Text("[QUESTION] Which color?\n"), fakeapi.Text("Done\n"))
+	defer srv.Close()
+	_, plan := setupTask(t, srv)
+
+	for i := 0; i < 2; i++ {
+		if code, _, stderr := runTask(t, "task-2", "Paint it", plan); code != 0 {
+			t.Fatalf("run %d: exit code %d, stderr:\n%s", i, code, stderr)
+		}
+	}
+
+	if convs := srv.Conversations(); len(convs) != 1 {
+		t.Fatalf("expected the second run to reuse the conversation, got %v", convs)
+	}
+	for i, req := range srv.Requests() {
+		if req["conversation"] != "conv_fake_1" {
+			t.Errorf("request %d conversation = %v", i, req["conversation"])
+		}
+	}
+}
+
+func TestExecuteTaskHTTPErrorBlocks(t *testing.T) {
+	srv := fakeapi.New(fakeapi.Error(500, `{"error":{"message":"upstream exploded"}}`))
+	defer srv.Close()
+	_, plan := setupTask(t, srv)
+
+	code, stdout, stderr := runTask(t, "task-3", "Anything", plan)
+	if code != 3 {
+		t.Fatalf("exit code %d, want 3", code)
+	}
+	if !strings.Contains(stderr, "[BLOCKED] API error: HTTP 500") {
+		t.Errorf("stderr missing blocked marker:\n%s", stderr)
+	}
+	if strings.Contains(stdout, "[CODEX_COMPLETE]") {
+		t.Errorf("failed run must not report completion:\n%s", stdout)
+	}
+}
+
+func TestExecuteTaskRetriesRateLimit(t *testing.T) {
+	srv := fakeapi.New(
+		fakeapi.Turn{Status: 429, Body: `{"error":{"code":"rate_limit_exceeded"}}`, Header: map[string]string{"Retry-After-Ms": "10"}},
+		fakeapi.Text("Done\n"),
+	)
+	defer srv.Close()
+	_, plan := setupTask(t, srv)
+	t.Setenv("RETRY_MAX_ATTEMPTS", "3")
+
+	code, _, stderr := runTask(t, "task-4", "Anything", plan)
+	if code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	if !strings.Contains(stderr, "[RETRY]") || !strings.Contains(stderr, "HTTP 429") {
+		t.Errorf("stderr missing retry log:\n%s", stderr)
+	}
+}
+
+func TestExecuteTaskMaxIters(t *testing.T) {
+	srv := fakeapi.New(fakeapi.Calls(fakeapi.Call("Glob", `{"pattern":"*.md"}`)))
+	defer srv.Close()
+	_, plan := setupTask(t, srv)
+	t.Setenv("MAX_ITERS", "1")
+
+	code, _, stderr := runTask(t, "task-5", "Loop forever", plan)
+	if code != 3 || !strings.Contains(stderr, "[BLOCKED] reached MAX_ITERS=1") {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+}
+
+func TestExecuteTaskUsageErrors(t *testing.T) {
+	srv := fakeapi.New()
+	defer srv.Close()
+	_, plan := setupTask(t, srv)
+
+	if code, _, _ := runTask(t, "task-6", "missing plan"); code != 2 {
+		t.Errorf("missing args: exit code %d, want 2", code)
+	}
+	if code, _, _ := runTask(t, "../escape", "desc", plan); code != 2 {
+		t.Errorf("invalid task ID: exit code %d, want 2", code)
+	}
+
+	t.Setenv("OPENAI_API_KEY", "")
+	if code, _, stderr := runTask(t, "task-6", "desc", plan); code != 2 || !strings.Contains(stderr, "OPENAI_API_KEY is required") {
+		t.Errorf("missing key: exit code %d, stderr:\n%s", code, stderr)
+	}
+}
+
+func TestExecuteTaskCompatibleProviderKeepsHistoryLocally(t *testing.T) {
+	srv := fakeapi.New(
+		fakeapi.Calls(fakeapi.Call("Glob", `{"pattern":"*.md"}`)),
+		fakeapi.Text("Done\n"),
+	)
+	defer srv.Close()
+	_, plan := setupTask(t, srv)
+	t.Setenv("OPENAI_PROVIDER", "compatible")
+	t.Setenv("OPENAI_API_KEY", "")
+
+	if code, _, stderr := runTask(t, "task-7", "List plans", plan); code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	if convs := srv.Conversations(); len(convs) != 0 {
+		t.Errorf("compatible provider must not call /conversations, got %v", convs)
+	}
+
+	reqs := srv.Requests()
+	if _, ok := reqs[1]["conversation"]; ok {
+		t.Error("conversation must not be sent to a compatible backend")
+	}
+	// developer prompt + user task + function_call + function_call_output
+	if input := reqs[1]["input"].([]interface{}); len(input) != 4 {
+		t.Errorf("second request replays %d items, want 4", len(input))
+	}
+}
+
+func TestExecuteTaskUsageAccounting(t *testing.T) {
+	srv := fakeapi.New(
+		fakeapi.Calls(fakeapi.Call("Glob", `{"pattern":"*.md"}`)).WithUsage(1000, 0, 200, 150),
+		fakeapi.Text("Done\n").WithUsage(1500, 1000, 100, 50),
+		fakeapi.Text("Again\n").WithUsage(2000, 1500, 10, 0),
+	)
+	defer srv.Close()
+	repoRoot, plan := setupTask(t, srv)
+	t.Setenv("OPENAI_MODEL", "priced-model")
+	t.Setenv("PRICE_TABLE", `{"priced-model":{"input":1,"cached_input":0.5,"output":10}}`)
+
+	code, _, stderr := runTask(t, "task-8", "Count tokens", plan)
+	if code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	// 1500 uncached + 1000 cached + 300 output = 0.0015 + 0.0005 + 0.003
+	want := "[USAGE] This run: 2 requests, 2500 input tokens (1000 cached), 300 output tokens (200 reasoning), est. $0.0050"
+	if !strings.Contains(stderr, want) {
+		t.Errorf("stderr missing usage summary %q:\n%s", want, stderr)
+	}
+
+	if code, _, stderr = runTask(t, "task-8", "Count tokens", plan); code != 0 {
+		t.Fatalf("second run: exit code %d, stderr:\n%s", code, stderr)
+	}
+	if !strings.Contains(stderr, "[USAGE] Session total: 3 requests, 4500 input tokens (2500 cached), 310 output tokens (200 reasoning), est. $0.0063") {
+		t.Errorf("stderr missing session totals:\n%s", stderr)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(repoRoot, ".codex-sessions", "tasks", "task-8.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sess map[string]interface{}
+	json.Unmarshal(raw, &sess)
+	if usage, _ := sess["usage"].(map[string]interface{}); usage["input_tokens"] != float64(4500) {
+		t.Errorf("session usage = %v", sess["usage"])
+	}
+}
+
+func TestExecuteTaskUnknownModelPrice(t *testing.T) {
+	srv := fakeapi.New(fakeapi.Text("Done\n").WithUsage(10, 0, 5, 0))
+	defer srv.Close()
+	_, plan := setupTask(t, srv)
+	t.Setenv("OPENAI_MODEL", "mystery-model")
+
+	code, _, stderr := runTask(t, "task-9", "Anything", plan)
+	if code != 0 || !strings.Contains(stderr, "cost unknown (no price for mystery-model") {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+
+	t.Setenv("PRICE_TABLE", "{broken")
+	if code, _, _ := runTask(t, "task-9", "Anything", plan); code != 2 {
+		t.Errorf("invalid PRICE_TABLE: exit code %d, want 2", code)
+	}
+}
+
+func TestExecuteTaskBudgetExceeded(t *testing.T) {
+	srv := fakeapi.New(
+		fakeapi.Calls(fakeapi.ToolCall{CallID: "w", Name: "Write", Arguments: `{"path":"a.txt","content":"a"}`}).WithUsage(5000, 0, 1000, 0),
+	)
+	defer srv.Close()
+	_, plan := setupTask(t, srv)
+	t.Setenv("MAX_TOKENS", "4000")
+
+	code, stdout, stderr := runTask(t, "task-10", "Spend", plan)
+	if code != 3 {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "[BLOCKED] budget exceeded: 6000 tokens used, limit MAX_TOKENS=4000") ||
+		!strings.Contains(stdout, "[FILES_MODIFIED]\n- a.txt") {
+		t.Errorf("stdout missing budget marker:\n%s", stdout)
+	}
+
+	// Raising the limit resumes the same conversation with the saved tool output
+	srv.Push(fakeapi.Text("Done\n"))
+	t.Setenv("MAX_TOKENS", "")
+	if code, _, stderr := runTask(t, "task-10", "Spend", plan); code != 0 {
+		t.Fatalf("resume: exit code %d, stderr:\n%s", code, stderr)
+	}
+	if out := srv.FunctionOutputs(1)["w"]; out == "" {
+		t.Error("resumed run must deliver the pending Write output")
+	}
+	if convs := srv.Conversations(); len(convs) != 1 {
+		t.Errorf("conversations = %v", convs)
+	}
+}
+
+func TestExecuteTaskRecordsSessionMetadata(t *testing.T) {
+	srv := fakeapi.New(
+		fakeapi.Calls(fakeapi.Call("Write", `{"path":"a.txt","content":"a"}`)),
+		fakeapi.Text("[QUESTION] Which color?\n"),
+		fakeapi.Text("Done\n"),
+	)
+	defer srv.Close()
+	repoRoot, plan := setupTask(t, srv)
+	t.Setenv("OPENAI_MODEL", "gpt-5.2-codex")
+	t.Setenv("REASONING_EFFORT", "high")
+	sessionFile := filepath.Join(repoRoot, ".codex-sessions", "tasks", "task-11.json")
+
+	// A legacy session file with only the conversation ID is picked up
+	os.MkdirAll(filepath.Dir(sessionFile), 0755)
+	os.WriteFile(sessionFile, []byte(`{"conversation_id":"conv_legacy"}`), 0644)
+
+	load := func() map[string]interface{} {
+		raw, err := os.ReadFile(sessionFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decodeResult(t, string(raw))
+	}
+
+	if code, _, stderr := runTask(t, "task-11", "Paint it", plan); code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	sess := load()
+	if sess["conversation_id"] != "conv_legacy" || sess["status"] != "question" {
+		t.Errorf("after first run: %v", sess)
+	}
+	if sess["kind"] != "task" || sess["model"] != "gpt-5.2-codex" || sess["reasoning_effort"] != "high" ||
+		sess["repo_root"] != repoRoot || sess["prompt"] != "Paint it" || sess["plan_file"] != plan {
+		t.Errorf("metadata = %v", sess)
+	}
+	// sha256("# Plan\n")
+	if sess["plan_sha256"] != "c3964bb3b70a957ec9b233c7dd3653f6ba17701ab00facf88ae1393dc6155577" {
+		t.Errorf("plan hash = %v", sess["plan_sha256"])
+	}
+	created := sess["created_at"]
+
+	if code, _, stderr := runTask(t, "task-11", "Paint it", plan); code != 0 {
+		t.Fatalf("second run: exit code %d, stderr:\n%s", code, stderr)
+	}
+	sess = load()
+	if sess["status"] != "complete" || sess["runs"] != float64(2) || sess["iterations"] != float64(3) || sess["last_iterations"] != float64(1) {
+		t.Errorf("after second run: %v", sess)
+	}
+	if files := sess["files_modified"].([]interface{}); len(files) != 1 || files[0] != "a.txt" {
+		t.Errorf("files modified = %v", files)
+	}
+	if sess["created_at"] != created || sess["updated_at"] == created {
+		t.Errorf("timestamps = %v / %v", sess["created_at"], sess["updated_at"])
+	}
+}
+
+func TestExecuteTaskTranscript(t *testing.T) {
+	srv := fakeapi.New(
+		fakeapi.Calls(
+			fakeapi.ToolCall{CallID: "w", Name: "Write", Arguments: `{"path":"config.py","content":"API_KEY = 'sk-live-abcdefghijklmnopqrstuv'\n"}`},
+			fakeapi.ToolCall{CallID: "env", Name: "Write", Arguments: `{"path":".env","content":"DB_PASSWORD=supersecret"}`},
+		).WithUsage(100, 0, 10, 0),
+		fakeapi.Text("[PROGRESS] Wrote config\n"),
+	)
+	defer srv.Close()
+	repoRoot, plan := setupTask(t, srv)
+
+	if code, _, stderr := runTask(t, "task-12", "Write config", plan); code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(repoRoot, ".codex-sessions", "tasks", "task-12.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcript := string(raw)
+	if strings.Contains(transcript, "sk-live") || strings.Contains(transcript, "supersecret") {
+		t.Errorf("transcript leaks secrets:\n%s", transcript)
+	}
+
+	var types []string
+	for _, line := range strings.Split(strings.TrimSpace(transcript), "\n") {
+		ev := decodeResult(t, line)
+		types = append(types, ev["type"].(string))
+		if ev["run"] != float64(1) {
+			t.Errorf("event run = %v", ev["run"])
+		}
+	}
+	want := "system run_start user response function_call function_call tool_result tool_result response text run_end"
+	if got := strings.Join(types, " "); got != want {
+		t.Errorf("event types = %s\nwant %s", got, want)
+	}
+
+	code, stdout, _ := runTask(t, "transcript", "show", "task-12")
+	if code != 0 || !strings.Contains(stdout, "=== Run 1") || !strings.Contains(stdout, "USER: Execute Task #task-12: Write config") ||
+		!strings.Contains(stdout, "CALL Write") || !strings.Contains(stdout, "RESULT Write error: Write: access denied") ||
+		!strings.Contains(stdout, "MODEL: [PROGRESS] Wrote config") || !strings.Contains(stdout, "End of run 1: complete after 2 iterations") {
+		t.Errorf("transcript show: exit code %d, stdout:\n%s", code, stdout)
+	}
+
+	// Removing the session removes its transcript
+	if code, _, _ := runTask(t, "sessions", "rm", "task-12"); code != 0 {
+		t.Fatalf("rm: exit code %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(repoRoot, ".codex-sessions", "tasks", "task-12.jsonl")); !os.IsNotExist(err) {
+		t.Error("transcript should be removed with the session")
+	}
+}
+
+func TestExecuteTaskRejectsConcurrentRun(t *testing.T) {
+	srv := fakeapi.New(fakeapi.Text("Done\n"))
+	defer srv.Close()
+	repoRoot, plan := setupTask(t, srv)
+	sessionsDir := filepath.Join(repoRoot, ".codex-sessions", "tasks")
+	os.MkdirAll(sessionsDir, 0755)
+
+	// Another process is running task-13
+	lock, err := session.Lock(context.Background(), sessionsDir, "task-13", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runTask(t, "task-13", "Anything", plan)
+	if code != 3 || !strings.Contains(stderr, "[BLOCKED] Task task-13: session is in use by another process") {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	if len(srv.Requests()) != 0 || len(srv.Conversations()) != 0 {
+		t.Error("a locked session must not reach the API")
+	}
+
+	// With SESSION_LOCK_WAIT the second run proceeds once the first ends
+	t.Setenv("SESSION_LOCK_WAIT", "5s")
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		lock.Unlock()
+	}()
+	if code, _, stderr := runTask(t, "task-13", "Anything", plan); code != 0 {
+		t.Fatalf("waiting run: exit code %d, stderr:\n%s", code, stderr)
+	}
+}
+
+func TestExecuteTaskRebuildsExpiredConversation(t *testing.T) {
+	srv := fakeapi.New(
+		fakeapi.Calls(fakeapi.ToolCall{CallID: "w", Name: "Write", Arguments: `{"path":"notes.txt","content":"v1\n"}`}),
+		fakeapi.Text("Wrote notes.txt\n"),
+	)
+	defer srv.Close()
+	repoRoot, plan := setupTask(t, srv)
+
+	if code, _, stderr := runTask(t, "task-15", "Write the notes", plan); code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+
+	// The server forgets the conversation between runs
+	srv.Expire("conv_fake_1")
+	srv.Push(fakeapi.Text("Notes updated\n"))
+	code, stdout, stderr := runTask(t, "task-15", "Update the notes", plan)
+	if code != 0 || !strings.Contains(stdout, "Notes updated") {
+		t.Fatalf("exit code %d, stdout:\n%s\nstderr:\n%s", code, stdout, stderr)
+	}
+	if !strings.Contains(stderr, "[RECOVER]") {
+		t.Errorf("stderr should report the rebuild:\n%s", stderr)
+	}
+
+	// The replacement is seeded with the system prompt and a summary of run 1
+	items := srv.Items("conv_fake_2")
+	seed, _ := items[0].(map[string]interface{})["content"].(string)
+	for _, want := range []string{"Task #task-15", "# Prior Session History", "## Run 1 (complete)", "Write notes.txt", "Wrote notes.txt"} {
+		if !strings.Contains(seed, want) {
+			t.Errorf("rebuilt system prompt missing %q:\n%s", want, seed)
+		}
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 4 || reqs[2]["conversation"] != "conv_fake_1" || reqs[3]["conversation"] != "conv_fake_2" {
+		t.Fatalf("requests should retry on the new conversation: %d requests", len(reqs))
+	}
+	input := reqs[3]["input"].([]interface{})
+	if len(input) != 1 || !strings.Contains(input[0].(map[string]interface{})["content"].(string), "Update the notes") {
+		t.Errorf("retried input = %v", input)
+	}
+
+	sess, err := session.Load(filepath.Join(repoRoot, ".codex-sessions", "tasks", "task-15.json"))
+	if err != nil || sess.ConversationID != "conv_fake_2" || sess.Runs != 2 || sess.Status != session.StatusComplete {
+		t.Errorf("session = %+v, %v", sess, err)
+	}
+}
+
+func TestExecuteTaskStatelessModes(t *testing.T) {
+	t.Run("local", func(t *testing.T) {
+		srv := fakeapi.New(
+			fakeapi.Calls(fakeapi.ToolCall{CallID: "r", Name: "Read", Arguments: `{"path":"plan.md"}`}),
+			fakeapi.Text("[QUESTION] Proceed?\n"),
+		)
+		defer srv.Close()
+		repoRoot, plan := setupTask(t, srv)
+		t.Setenv("OPENAI_STORE", "false")
+
+		if code, _, stderr := runTask(t, "task-16", "Read the plan", plan); code != 0 {
+			t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+		}
+		sess, _ := session.Load(filepath.Join(repoRoot, ".codex-sessions", "tasks", "task-16.json"))
+		if !strings.HasPrefix(sess.ConversationID, "local_") || len(sess.ConversationState) == 0 {
+			t.Fatalf("session should hold the history: %+v", sess)
+		}
+
+		// A new process continues from the history in the session file
+		srv.Push(fakeapi.Text("Done\n"))
+		if code, _, stderr := runTask(t, "task-16", "Yes", plan); code != 0 {
+			t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+		}
+		if convs := srv.Conversations(); len(convs) != 0 {
+			t.Errorf("local mode must not create conversations: %v", convs)
+		}
+		last := srv.Requests()[2]
+		if last["store"] != false || last["conversation"] != nil {
+			t.Errorf("request must be stateless: store=%v conversation=%v", last["store"], last["conversation"])
+		}
+		var kinds []string
+		for _, item := range last["input"].([]interface{}) {
+			m := item.(map[string]interface{})
+			if _, hasID := m["id"]; hasID {
+				t.Errorf("history items must not carry IDs when store=false: %v", m)
+			}
+			kinds = append(kinds, fmt.Sprint(m["role"], m["type"]))
+		}
+		want := "developer<nil> user<nil> <nil>function_call <nil>function_call_output assistantmessage user<nil>"
+		if got := strings.Join(kinds, " "); got != want {
+			t.Errorf("replayed input = %s\nwant %s", got, want)
+		}
+	})
+
+	t.Run("chain", func(t *testing.T) {
+		srv := fakeapi.New(fakeapi.Text("[QUESTION] Proceed?\n"))
+		defer srv.Close()
+		_, plan := setupTask(t, srv)
+		t.Setenv("CONVERSATION_MODE", "chain")
+
+		if code, _, stderr := runTask(t, "task-16", "Read the plan", plan); code != 0 {
+			t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+		}
+		srv.Push(fakeapi.Text("Done\n"))
+		if code, _, stderr := runTask(t, "task-16", "Yes", plan); code != 0 {
+			t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+		}
+
+		reqs := srv.Requests()
+		first, second := reqs[0], reqs[1]
+		if input := first["input"].([]interface{}); len(input) != 2 || input[0].(map[string]interface{})["role"] != "developer" {
+			t.Errorf("first request must carry the system prompt: %v", input)
+		}
+		if _, ok := first["previous_response_id"]; ok {
+			t.Error("first request has nothing to chain from")
+		}
+		if second["previous_response_id"] != "resp_fake_1" || len(second["input"].([]interface{})) != 1 {
+			t.Errorf("second request = previous_response_id %v, input %v", second["previous_response_id"], second["input"])
+		}
+		if convs := srv.Conversations(); len(convs) != 0 {
+			t.Errorf("chain mode must not create conversations: %v", convs)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		srv := fakeapi.New()
+		defer srv.Close()
+		_, plan := setupTask(t, srv)
+		t.Setenv("CONVERSATION_MODE", "server")
+		t.Setenv("OPENAI_STORE", "false")
+		if code, _, stderr := runTask(t, "task-16", "x", plan); code != 2 || !strings.Contains(stderr, "requires CONVERSATION_MODE=local") {
+			t.Errorf("exit code %d, stderr:\n%s", code, stderr)
+		}
+	})
+}
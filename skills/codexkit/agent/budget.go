@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"codexkit/api"
+)
+
+// Budget caps a single run; zero fields are unlimited. Limits are checked
+// between iterations, so one large iteration can overshoot them.
+type Budget struct {
+	MaxTokens   int64         // Input + output tokens reported by the API
+	MaxDuration time.Duration // Wall-clock time since Run started
+	MaxCostUSD  float64       // Estimated spend, priced with Prices
+	Prices      api.PriceTable
+}
+
+// ErrBudgetExceeded is wrapped by the error Run returns when a Budget limit is hit
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// BudgetFromEnv reads MAX_TOKENS, MAX_DURATION (seconds or a Go duration
+// like 15m) and MAX_COST_USD. A cost limit needs a price for model.
+func BudgetFromEnv(model string, prices api.PriceTable) (Budget, error) {
+	b := Budget{
+		MaxTokens:   int64(GetEnvInt("MAX_TOKENS", 0)),
+		MaxDuration: GetEnvDuration("MAX_DURATION", 0),
+		MaxCostUSD:  GetEnvFloat("MAX_COST_USD", 0),
+		Prices:      prices,
+	}
+	if b.MaxCostUSD > 0 {
+		if _, ok := prices.Lookup(model); !ok {
+			return b, fmt.Errorf("MAX_COST_USD is set but there is no price for model %s (set PRICE_TABLE)", model)
+		}
+	}
+	return b, nil
+}
+
+// check returns an ErrBudgetExceeded error once usage or elapsed time passes a limit
+func (b Budget) check(model string, usage api.Usage, elapsed time.Duration) error {
+	if b.MaxTokens > 0 && usage.Total() >= b.MaxTokens {
+		return fmt.Errorf("%w: %d tokens used, limit MAX_TOKENS=%d", ErrBudgetExceeded, usage.Total(), b.MaxTokens)
+	}
+	if b.MaxDuration > 0 && elapsed >= b.MaxDuration {
+		return fmt.Errorf("%w: ran for %s, limit MAX_DURATION=%s", ErrBudgetExceeded, elapsed.Round(time.Second), b.MaxDuration)
+	}
+	if b.MaxCostUSD > 0 {
+		if cost, ok := EstimateCost(b.Prices, model, usage); ok && cost >= b.MaxCostUSD {
+			return fmt.Errorf("%w: spent est. $%.4f, limit MAX_COST_USD=%.2f", ErrBudgetExceeded, cost, b.MaxCostUSD)
+		}
+	}
+	return nil
+}
+
+// ReportBudgetExceeded prints the [BLOCKED] marker for a run stopped by its budget
+func ReportBudgetExceeded(w io.Writer, res Result, err error) {
+	fmt.Fprintf(w, "\n[BLOCKED] %v\n", err)
+	fmt.Fprintf(w, "Stopped after %d iterations; raise the limit and re-run with the same ID to continue.\n", res.Iterations)
+	reportFilesModified(w, res.FilesModified)
+}
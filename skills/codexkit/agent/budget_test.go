@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"codexkit/api"
+	"codexkit/fakeapi"
+	"codexkit/fstools"
+)
+
+func TestBudgetCheck(t *testing.T) {
+	prices := api.PriceTable{"m": {Input: 10, Output: 10}}
+	usage := api.Usage{InputTokens: 90_000, OutputTokens: 10_000} // est. $1.00
+
+	cases := []struct {
+		name   string
+		budget Budget
+		want   string
+	}{
+		{"unlimited", Budget{}, ""},
+		{"tokens", Budget{MaxTokens: 100_000}, "MAX_TOKENS=100000"},
+		{"tokens under", Budget{MaxTokens: 100_001}, ""},
+		{"duration", Budget{MaxDuration: time.Minute}, "MAX_DURATION=1m0s"},
+		{"cost", Budget{MaxCostUSD: 0.5, Prices: prices}, "MAX_COST_USD=0.50"},
+		{"cost under", Budget{MaxCostUSD: 2, Prices: prices}, ""},
+	}
+	for _, tc := range cases {
+		err := tc.budget.check("m", usage, 2*time.Minute)
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected %v", tc.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrBudgetExceeded) || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestBudgetFromEnvRequiresPriceForCostLimit(t *testing.T) {
+	t.Setenv("MAX_COST_USD", "5")
+	if _, err := BudgetFromEnv("unpriced", api.DefaultPrices()); err == nil {
+		t.Error("cost limit without a price must be rejected")
+	}
+
+	t.Setenv("MAX_DURATION", "90")
+	t.Setenv("MAX_TOKENS", "5000")
+	b, err := BudgetFromEnv("gpt-5", api.DefaultPrices())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.MaxDuration != 90*time.Second || b.MaxTokens != 5000 || b.MaxCostUSD != 5 {
+		t.Errorf("budget = %+v", b)
+	}
+}
+
+func TestRunStopsWhenBudgetExceeded(t *testing.T) {
+	srv := fakeapi.New(
+		fakeapi.Calls(fakeapi.ToolCall{CallID: "w", Name: "Write", Arguments: `{"path":"a.txt","content":"a"}`}).WithUsage(800, 0, 300, 0),
+		fakeapi.Text("never reached"),
+	)
+	defer srv.Close()
+	cfg := newTestConfig(t, srv, fstools.ReadWriteTools())
+	cfg.Budget = Budget{MaxTokens: 1000}
+
+	res, err := Run(context.Background(), cfg, "write")
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("err = %v, want ErrBudgetExceeded", err)
+	}
+	if res.Iterations != 1 || len(res.FilesModified) != 1 {
+		t.Errorf("result = %+v", res)
+	}
+	// The tool output is kept so the conversation can be resumed
+	if len(res.Pending) != 1 || res.Pending[0]["call_id"] != "w" {
+		t.Errorf("pending = %v", res.Pending)
+	}
+	if srv.Remaining() != 1 {
+		t.Error("no request may be sent after the budget is exceeded")
+	}
+}
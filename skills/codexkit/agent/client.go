@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"codexkit/api"
+)
+
+// NewProviderFromEnv builds the API backend from the environment:
+//
+//	OPENAI_PROVIDER       openai (default), azure or compatible
+//	OPENAI_BASE_URL       API base URL (required for azure and compatible)
+//	OPENAI_API_KEY        API key (optional for compatible local servers)
+//	AZURE_API_VERSION     api-version query parameter for azure
+//	CONVERSATION_MODE     server (Conversations API, default for openai),
+//	                      chain (previous_response_id) or local (history kept
+//	                      client-side, default for azure and compatible)
+//	OPENAI_CONVERSATIONS  false selects local mode (older spelling)
+//	OPENAI_STORE          false sends store=false (zero data retention);
+//	                      implies and requires local mode
+//	RETRY_MAX_ATTEMPTS    attempts per API call on transient errors
+//	RETRY_MAX_WAIT        max total seconds spent waiting between retries
+//
+// Retries are logged to log.
+func NewProviderFromEnv(log io.Writer) (api.Provider, error) {
+	kind := GetEnv("OPENAI_PROVIDER", "openai")
+	apiKey := os.Getenv("OPENAI_API_KEY")
+
+	client := api.NewClient(apiKey)
+	client.BaseURL = GetEnv("OPENAI_BASE_URL", api.DefaultBaseURL)
+
+	conversations := true
+	switch kind {
+	case "openai":
+		if apiKey == "" {
+			return nil, errors.New("OPENAI_API_KEY is required")
+		}
+	case "azure":
+		if apiKey == "" {
+			return nil, errors.New("OPENAI_API_KEY is required")
+		}
+		if os.Getenv("OPENAI_BASE_URL") == "" {
+			return nil, errors.New("OPENAI_BASE_URL is required for OPENAI_PROVIDER=azure (e.g. https://<resource>.openai.azure.com/openai/v1)")
+		}
+		client.AuthHeader = "api-key"
+		if version := os.Getenv("AZURE_API_VERSION"); version != "" {
+			client.Query = url.Values{"api-version": {version}}
+		}
+		conversations = false
+	case "compatible":
+		if os.Getenv("OPENAI_BASE_URL") == "" {
+			return nil, errors.New("OPENAI_BASE_URL is required for OPENAI_PROVIDER=compatible (e.g. http://localhost:8000/v1)")
+		}
+		conversations = false
+	default:
+		return nil, fmt.Errorf("unknown OPENAI_PROVIDER %q (use openai, azure or compatible)", kind)
+	}
+
+	retry := api.DefaultRetryPolicy()
+	retry.MaxAttempts = GetEnvInt("RETRY_MAX_ATTEMPTS", retry.MaxAttempts)
+	retry.MaxWait = time.Duration(GetEnvInt("RETRY_MAX_WAIT", int(retry.MaxWait/time.Second))) * time.Second
+	retry.Log = log
+	client.Retry = retry
+
+	store := GetEnvBool("OPENAI_STORE", true)
+	mode := os.Getenv("CONVERSATION_MODE")
+	if mode == "" {
+		mode = "server"
+		if !GetEnvBool("OPENAI_CONVERSATIONS", conversations) || !store {
+			mode = "local"
+		}
+	}
+	if !store && mode != "local" {
+		return nil, fmt.Errorf("OPENAI_STORE=false requires CONVERSATION_MODE=local (%s mode keeps history on the server)", mode)
+	}
+
+	switch mode {
+	case "server":
+		return client, nil
+	case "chain":
+		return api.NewChainAdapter(client), nil
+	case "local":
+		history := api.NewHistoryAdapter(client)
+		history.NoStore = !store
+		return history, nil
+	}
+	return nil, fmt.Errorf("unknown CONVERSATION_MODE %q (use server, chain or local)", mode)
+}
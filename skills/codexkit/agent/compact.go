@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"codexkit/api"
+	"codexkit/session"
+)
+
+// Context compaction modes (CONTEXT_COMPACTION)
+const (
+	CompactSummary  = "summary"  // Continue in a new conversation seeded with a transcript summary
+	CompactTruncate = "truncate" // Let the API drop the oldest items ("truncation": "auto")
+	CompactOff      = "off"
+)
+
+// defaultCompactThreshold leaves room for a round of tool outputs below the
+// 272k-token input window of the gpt-5 family
+const defaultCompactThreshold = 200_000
+
+// Compaction controls what the loop does when the conversation outgrows
+// the model's context window. The zero value compacts with a summary, but
+// only after the API rejects a request as too long.
+type Compaction struct {
+	Mode string // CompactSummary (default), CompactTruncate or CompactOff
+
+	// Threshold compacts before a request whose approximate context (the
+	// last response's input + output tokens plus the new input) exceeds it;
+	// 0 waits for a context-length error
+	Threshold int64
+}
+
+// CompactionFromEnv reads CONTEXT_COMPACTION and COMPACT_AT_TOKENS
+func CompactionFromEnv() (Compaction, error) {
+	c := Compaction{
+		Mode:      GetEnv("CONTEXT_COMPACTION", CompactSummary),
+		Threshold: int64(GetEnvInt("COMPACT_AT_TOKENS", defaultCompactThreshold)),
+	}
+	switch c.Mode {
+	case CompactSummary, CompactTruncate, CompactOff:
+		return c, nil
+	}
+	return c, fmt.Errorf("unknown CONTEXT_COMPACTION %q (use summary, truncate or off)", c.Mode)
+}
+
+// Reasons passed to Config.Rebuild, written above the transcript summary
+const (
+	ReasonExpired   = "The earlier conversation for this session no longer exists on the server."
+	ReasonCompacted = "The conversation outgrew the model's context window and was compacted."
+)
+
+// Limits on tool results carried into a compacted conversation
+const (
+	carriedResultLimit = 4 << 10
+	carriedTotalLimit  = 32 << 10
+)
+
+// estimateTokens approximates the token count of v at ~4 bytes per token
+func estimateTokens(v interface{}) int64 {
+	data, _ := json.Marshal(v)
+	return int64(len(data) / 4)
+}
+
+// continuation is the input that resumes a run after compaction: the
+// prompt again, plus any tool results the old conversation was about to
+// receive, as text since their function calls are gone
+func continuation(prompt string, input []map[string]interface{}, calls map[string]api.FunctionCall) []map[string]interface{} {
+	var b strings.Builder
+	b.WriteString("[CONTEXT COMPACTED] The previous conversation outgrew the context window. ")
+	b.WriteString("Earlier work is summarized in the developer message; re-read files before relying on details.\n\n")
+	b.WriteString("Continue with the current request:\n")
+	b.WriteString(prompt)
+	b.WriteString("\n")
+
+	total := 0
+	for _, item := range input {
+		if item["type"] != "function_call_output" {
+			continue
+		}
+		if total == 0 {
+			b.WriteString("\nResults of your last tool calls (clipped):\n")
+		}
+		call := calls[fmt.Sprint(item["call_id"])]
+		output := session.Clip(fmt.Sprint(item["output"]), min(carriedResultLimit, carriedTotalLimit-total))
+		total += len(output)
+		fmt.Fprintf(&b, "\n### %s %s\n%s\n", call.Name, session.Clip(call.Arguments, 200), output)
+		if total >= carriedTotalLimit {
+			b.WriteString("\n(remaining results omitted)\n")
+			break
+		}
+	}
+
+	return []map[string]interface{}{{"role": "user", "content": b.String()}}
+}
@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetEnv returns an environment variable or a default
+func GetEnv(key, defaultVal string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return defaultVal
+}
+
+// GetEnvInt returns an integer environment variable or a default
+func GetEnvInt(key string, defaultVal int) int {
+	if val := os.Getenv(key); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			return i
+		}
+	}
+	return defaultVal
+}
+
+// GetEnvBool returns a boolean environment variable (1/true/yes/on) or a default
+func GetEnvBool(key string, defaultVal bool) bool {
+	switch strings.ToLower(os.Getenv(key)) {
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	}
+	return defaultVal
+}
+
+// GetEnvDuration returns a duration environment variable, given in seconds
+// or as a Go duration (90s, 15m, 1h30m), or a default
+func GetEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal
+	}
+	if secs, err := strconv.Atoi(val); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if d, err := time.ParseDuration(val); err == nil {
+		return d
+	}
+	return defaultVal
+}
+
+// GetEnvFloat returns a float environment variable or a default
+func GetEnvFloat(key string, defaultVal float64) float64 {
+	if val := os.Getenv(key); val != "" {
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f
+		}
+	}
+	return defaultVal
+}
+
+// DetectRepoRoot resolves REPO_ROOT or walks up to the nearest .git
+func DetectRepoRoot() (string, error) {
+	if root := os.Getenv("REPO_ROOT"); root != "" {
+		return filepath.Abs(root)
+	}
+
+	// Walk up to find .git directory
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	dir := cwd
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	// No git found, use cwd
+	return cwd, nil
+}
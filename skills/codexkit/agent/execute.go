@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"codexkit/api"
+	"codexkit/fstools"
+)
+
+// defaultMaxParallelTools bounds concurrent tool calls within one turn
+const defaultMaxParallelTools = 8
+
+// callOutcome is what one function call produced
+type callOutcome struct {
+	call   api.FunctionCall
+	args   string // Arguments as executed (for tracing)
+	result fstools.ToolResult
+	output []byte // Encoded function_call_output
+	took   time.Duration
+	ran    bool // False for invalid arguments and interrupted runs
+}
+
+// executeCalls runs one turn's function calls and returns their outcomes
+// in call order. With cfg.ParallelToolCalls the calls share a bounded
+// pool: calls that may see each other's writes form groups that run in the
+// model's order (see groupCalls), everything else runs independently.
+func executeCalls(ctx context.Context, cfg Config, calls []api.FunctionCall) []callOutcome {
+	outcomes := make([]callOutcome, len(calls))
+	workers := cmp.Or(cfg.MaxParallelTools, defaultMaxParallelTools)
+	if !cfg.ParallelToolCalls || workers <= 1 || len(calls) <= 1 {
+		for i, call := range calls {
+			outcomes[i] = executeCall(ctx, cfg, call)
+		}
+		return outcomes
+	}
+
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for _, group := range groupCalls(cfg.Tools, calls) {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(group []int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			for _, i := range group {
+				outcomes[i] = executeCall(ctx, cfg, calls[i])
+			}
+		}(group)
+	}
+	wg.Wait()
+	return outcomes
+}
+
+// groupCalls splits calls into groups of indexes that may run concurrently
+// with each other; calls within a group must run in order. Calls on a path
+// that the turn writes share a group per path. Calls without a path (Grep,
+// Glob) may read any of those paths, so in a turn that writes they join
+// every such call in a single group.
+func groupCalls(tools fstools.Registry, calls []api.FunctionCall) [][]int {
+	written := map[string]bool{}
+	for _, call := range calls {
+		if p := callPath(call); p != "" && tools.Mutates(call.Name) {
+			written[p] = true
+		}
+	}
+
+	const anyPath = "\x00" // Key of the group serializing path-less calls
+	keys := make([]string, len(calls))
+	serializeAll := false
+	for i, call := range calls {
+		switch p := callPath(call); {
+		case written[p]:
+			keys[i] = p
+		case p == "" && len(written) > 0:
+			keys[i] = anyPath
+			serializeAll = true
+		}
+	}
+
+	var groups [][]int
+	byKey := map[string]int{}
+	for i, key := range keys {
+		if key != "" && serializeAll {
+			key = anyPath
+		}
+		if key != "" {
+			if g, ok := byKey[key]; ok {
+				groups[g] = append(groups[g], i)
+				continue
+			}
+			byKey[key] = len(groups)
+		}
+		groups = append(groups, []int{i})
+	}
+	return groups
+}
+
+// callPath returns the normalized "path" argument of a call, or ""
+func callPath(call api.FunctionCall) string {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if json.Unmarshal([]byte(call.Arguments), &args) != nil || args.Path == "" {
+		return ""
+	}
+	return path.Clean(filepath.ToSlash(args.Path))
+}
+
+// executeCall runs a single function call; a cancelled run answers it
+// without running it
+func executeCall(ctx context.Context, cfg Config, call api.FunctionCall) callOutcome {
+	out := callOutcome{call: call, args: call.Arguments}
+	if out.args == "" {
+		out.args = "{}" // Default to empty args
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		out.result = fstools.ToolResult{OK: false, Tool: call.Name, Error: "Not executed: run was interrupted"}
+	default:
+		var args map[string]interface{}
+		if err := json.Unmarshal([]byte(out.args), &args); err != nil {
+			out.result = fstools.ToolResult{OK: false, Tool: call.Name, Error: fmt.Sprintf("Invalid arguments: %v", err)}
+			break
+		}
+		start := time.Now()
+		out.result = cfg.Tools.Execute(cfg.RepoRoot, call.Name, args)
+		out.took = time.Since(start)
+		out.ran = true
+	}
+
+	out.output = encodeResult(out.result, cfg.ResultBudget)
+	return out
+}
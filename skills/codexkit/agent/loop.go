@@ -0,0 +1,326 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"codexkit/api"
+	"codexkit/fstools"
+	"codexkit/session"
+)
+
+// Config describes one run of the tool loop
+type Config struct {
+	Provider          api.Provider
+	Model             string
+	ReasoningEffort   string
+	ConversationID    string
+	RepoRoot          string
+	MaxIters          int
+	Tools             fstools.Registry
+	ParallelToolCalls bool
+	MaxParallelTools  int  // Concurrent tool calls per turn with ParallelToolCalls (0 = 8)
+	TraceToolCalls    bool // Print [TOOL_CALL] lines to Stderr
+	ResultBudget      int  // Max bytes of one tool result (0 = fstools.DefaultResultBudget)
+	Stream            bool // Print output_text deltas as they arrive
+	Budget            Budget
+	Compaction        Compaction
+	Transcript        *session.Transcript // Optional JSONL log of every turn
+
+	// Pending function_call_output items from an earlier run, sent before
+	// the prompt (see Result.Pending)
+	Pending []map[string]interface{}
+
+	// Rebuild, if set, returns a replacement conversation seeded with a
+	// summary of the session, reason (ReasonExpired or ReasonCompacted)
+	// explaining why. It is called when the API reports that the
+	// conversation or previous response no longer exists, and to compact a
+	// conversation that outgrew the context window (CompactSummary).
+	Rebuild func(ctx context.Context, reason string) (string, error)
+
+	Stdout io.Writer // Defaults to os.Stdout
+	Stderr io.Writer // Defaults to os.Stderr
+}
+
+// Result summarizes a run, including runs that stopped early
+type Result struct {
+	Iterations    int
+	FilesModified []string  // Paths written or edited, in first-touch order
+	Usage         api.Usage // Tokens reported across all responses
+	Text          string    // Output text of the last response
+	LastResponse  string    // ID of the last response received
+
+	// Pending holds function_call_output items that were produced but not
+	// delivered; persist them and pass them back via Config.Pending
+	Pending []map[string]interface{}
+}
+
+// ErrInterrupted is returned when ctx is cancelled (SIGINT/SIGTERM)
+var ErrInterrupted = errors.New("interrupted")
+
+// Run executes the tool loop with the Responses API until the model stops
+// calling tools. On cancellation the in-flight request is aborted, the
+// current tool call is allowed to finish, and ErrInterrupted is returned.
+// Budget limits are checked between iterations (see ErrBudgetExceeded).
+func Run(ctx context.Context, cfg Config, prompt string) (Result, error) {
+	cfg.Transcript.Record(session.Event{Type: session.EventRunStart, Name: cfg.Model})
+	cfg.Transcript.Record(session.Event{Type: session.EventUser, Text: fstools.RedactSecrets(prompt)})
+
+	res, err := run(ctx, cfg, prompt)
+
+	end := session.Event{Type: session.EventRunEnd, Status: Status(res, err), Iteration: res.Iterations, Usage: &res.Usage}
+	if err != nil {
+		end.Text = err.Error()
+	}
+	cfg.Transcript.Record(end)
+	return res, err
+}
+
+func run(ctx context.Context, cfg Config, prompt string) (Result, error) {
+	stdout, stderr := cfg.Stdout, cfg.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	tools := cfg.Tools.Schema()
+
+	start := time.Now()
+	var res Result
+	touched := map[string]bool{}
+
+	// Context tracking for compaction: the size of the conversation after
+	// the last response, and the calls the pending outputs answer
+	var contextTokens int64
+	calls := map[string]api.FunctionCall{}
+	compacted := false // No response since the last compaction
+	rebuilt := false
+	compactSummary := cfg.Compaction.Mode == "" || cfg.Compaction.Mode == CompactSummary
+
+	// Initial input: undelivered outputs first, then the prompt
+	inputItems := append([]map[string]interface{}{}, cfg.Pending...)
+	inputItems = append(inputItems, map[string]interface{}{
+		"role":    "user",
+		"content": prompt,
+	})
+
+	// stop records undelivered outputs before returning early
+	stop := func(err error) (Result, error) {
+		res.Pending = pendingOutputs(inputItems)
+		if ctx.Err() != nil {
+			return res, ErrInterrupted
+		}
+		return res, err
+	}
+
+	// compact moves the run to a new conversation seeded with a summary
+	compact := func(why string) error {
+		fmt.Fprintf(stderr, "[COMPACT] %s; continuing in a new conversation seeded with a summary\n", why)
+		id, err := cfg.Rebuild(ctx, ReasonCompacted)
+		if err != nil {
+			return fmt.Errorf("compacting conversation: %w", err)
+		}
+		cfg.ConversationID = id
+		inputItems = continuation(prompt, inputItems, calls)
+		contextTokens = 0
+		compacted = true
+		return nil
+	}
+
+	for iteration := 0; iteration < cfg.MaxIters; iteration++ {
+		// Compact ahead of time once the next request would pass the threshold
+		if estimate := contextTokens + estimateTokens(inputItems); compactSummary && cfg.Rebuild != nil && !compacted &&
+			cfg.Compaction.Threshold > 0 && estimate > cfg.Compaction.Threshold {
+			if err := compact(fmt.Sprintf("context is about %d tokens (COMPACT_AT_TOKENS=%d)", estimate, cfg.Compaction.Threshold)); err != nil {
+				return stop(err)
+			}
+		}
+
+		// Build payload
+		payload := map[string]interface{}{
+			"model":               cfg.Model,
+			"tools":               tools,
+			"tool_choice":         "auto",
+			"parallel_tool_calls": cfg.ParallelToolCalls,
+			"input":               inputItems,
+		}
+		if cfg.ConversationID != "" {
+			payload["conversation"] = cfg.ConversationID
+		}
+
+		if cfg.Compaction.Mode == CompactTruncate {
+			payload["truncation"] = "auto"
+		}
+
+		if cfg.ReasoningEffort != "" {
+			payload["reasoning"] = map[string]interface{}{
+				"effort": cfg.ReasoningEffort,
+			}
+		}
+
+		// Call Responses API (streamed text is printed as it arrives)
+		var respData map[string]interface{}
+		var err error
+		requestStart := time.Now()
+		if cfg.Stream {
+			respData, err = cfg.Provider.StreamResponse(ctx, payload, func(delta string) {
+				fmt.Fprint(stdout, delta)
+			})
+		} else {
+			respData, err = cfg.Provider.CreateResponse(ctx, payload)
+		}
+		if err != nil && cfg.Rebuild != nil && !rebuilt && api.IsConversationNotFound(err) && ctx.Err() == nil {
+			fmt.Fprintf(stderr, "[RECOVER] %v; rebuilding the conversation from local history\n", err)
+			id, rebuildErr := cfg.Rebuild(ctx, ReasonExpired)
+			if rebuildErr != nil {
+				return stop(fmt.Errorf("API error: %w (rebuilding conversation failed: %v)", err, rebuildErr))
+			}
+			// Outputs in flight answered calls of the lost conversation
+			rebuilt = true
+			cfg.ConversationID = id
+			inputItems = []map[string]interface{}{{"role": "user", "content": prompt}}
+			contextTokens = 0
+			iteration--
+			continue
+		}
+		if err != nil && compactSummary && cfg.Rebuild != nil && !compacted && api.IsContextLengthExceeded(err) && ctx.Err() == nil {
+			if compactErr := compact("request exceeded the context window"); compactErr != nil {
+				return stop(fmt.Errorf("API error: %w (%v)", err, compactErr))
+			}
+			iteration--
+			continue
+		}
+		if err != nil {
+			return stop(fmt.Errorf("API error: %w", err))
+		}
+		compacted = false
+		res.Iterations = iteration + 1
+		if id, _ := respData["id"].(string); id != "" {
+			res.LastResponse = id
+		}
+		usage, hasUsage := api.ExtractUsage(respData)
+		if hasUsage {
+			res.Usage.Add(usage)
+			contextTokens = usage.InputTokens + usage.OutputTokens
+		} else {
+			contextTokens += estimateTokens(inputItems) + estimateTokens(respData["output"])
+		}
+
+		// Extract tool calls and text
+		toolCalls, outputText := api.ExtractCallsAndText(respData)
+		res.Text = outputText
+		recordResponse(cfg.Transcript, res.Iterations, respData, time.Since(requestStart), usage, hasUsage, toolCalls, outputText)
+
+		// Print output text (includes markers)
+		if outputText != "" && !cfg.Stream {
+			fmt.Fprint(stdout, outputText)
+		}
+
+		if len(toolCalls) == 0 {
+			// No tool calls => done
+			return res, nil
+		}
+
+		// Execute tool calls (concurrently when the model issued them in parallel)
+		var valid []api.FunctionCall
+		clear(calls)
+		for _, call := range toolCalls {
+			if call.CallID != "" && call.Name != "" {
+				calls[call.CallID] = call
+				valid = append(valid, call)
+			}
+		}
+		outputs := []map[string]interface{}{}
+		for _, out := range executeCalls(ctx, cfg, valid) {
+			recordToolResult(cfg.Transcript, res.Iterations, out.call, out.output, out.took)
+
+			if out.ran && out.result.OK && cfg.Tools.Mutates(out.call.Name) && !touched[out.result.Path] {
+				touched[out.result.Path] = true
+				res.FilesModified = append(res.FilesModified, out.result.Path)
+			}
+
+			if out.ran && cfg.TraceToolCalls {
+				fmt.Fprintf(stderr, "[TOOL_CALL] %s(%s...)\n", out.call.Name, out.args[:min(100, len(out.args))])
+			}
+
+			outputs = append(outputs, map[string]interface{}{
+				"type":    "function_call_output",
+				"call_id": out.call.CallID,
+				"output":  string(out.output),
+			})
+		}
+
+		inputItems = outputs
+		if ctx.Err() != nil {
+			return stop(nil)
+		}
+		if err := cfg.Budget.check(cfg.Model, res.Usage, time.Since(start)); err != nil {
+			return stop(err)
+		}
+	}
+
+	return stop(fmt.Errorf("reached MAX_ITERS=%d without completion", cfg.MaxIters))
+}
+
+// Status classifies how a run ended, as recorded in the session file
+func Status(res Result, err error) string {
+	switch {
+	case errors.Is(err, ErrInterrupted):
+		return session.StatusInterrupted
+	case err != nil:
+		return session.StatusBlocked
+	case strings.Contains(res.Text, "[QUESTION]"):
+		return session.StatusQuestion
+	case strings.Contains(res.Text, "[BLOCKED]"):
+		return session.StatusBlocked
+	}
+	return session.StatusComplete
+}
+
+// RecordRun adds the outcome of a run to the session's running totals
+func RecordRun(sess *session.Data, res Result, err error, costUSD float64) {
+	sess.PendingOutputs = res.Pending
+	sess.Runs++
+	sess.Iterations += res.Iterations
+	sess.LastIterations = res.Iterations
+	sess.Status = Status(res, err)
+	sess.Error = ""
+	if err != nil && !errors.Is(err, ErrInterrupted) {
+		sess.Error = err.Error()
+	}
+	if res.LastResponse != "" {
+		sess.LastResponseID = res.LastResponse
+	}
+	sess.Usage.Add(res.Usage)
+	sess.CostUSD += costUSD
+	sess.AddFilesModified(res.FilesModified)
+}
+
+// encodeResult fits a tool result into the per-result budget and encodes it
+// as function_call_output text
+func encodeResult(result fstools.ToolResult, budget int) []byte {
+	if budget == 0 {
+		budget = fstools.DefaultResultBudget
+	}
+	data, _ := json.Marshal(result.Truncate(budget))
+	return data
+}
+
+// pendingOutputs returns the function_call_output items among input
+func pendingOutputs(input []map[string]interface{}) []map[string]interface{} {
+	var pending []map[string]interface{}
+	for _, item := range input {
+		if item["type"] == "function_call_output" {
+			pending = append(pending, item)
+		}
+	}
+	return pending
+}
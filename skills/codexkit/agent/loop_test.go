@@ -0,0 +1,354 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"codexkit/api"
+	"codexkit/fakeapi"
+	"codexkit/fstools"
+)
+
+func newTestConfig(t *testing.T, srv *fakeapi.Server, tools fstools.Registry) Config {
+	t.Helper()
+	client := api.NewClient("test-key")
+	client.BaseURL = srv.URL
+	client.Retry.MaxAttempts = 1
+	return Config{
+		Provider:       client,
+		Model:          "test-model",
+		ConversationID: "conv_test",
+		RepoRoot:       t.TempDir(),
+		MaxIters:       10,
+		Tools:          tools,
+		Stdout:         io.Discard,
+		Stderr:         io.Discard,
+	}
+}
+
+func TestRunInterruptFinishesCurrentToolCall(t *testing.T) {
+	srv := fakeapi.New(fakeapi.Calls(
+		fakeapi.ToolCall{CallID: "a", Name: "Write", Arguments: `{"path":"a.txt","content":"a"}`},
+		fakeapi.ToolCall{CallID: "stop", Name: "Stop", Arguments: `{}`},
+		fakeapi.ToolCall{CallID: "b", Name: "Write", Arguments: `{"path":"b.txt","content":"b"}`},
+	))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Stop simulates a signal arriving while a tool call is running
+	stopTool := fstools.Tool{Name: "Stop", Run: func(repoRoot string, args fstools.Args) fstools.ToolResult {
+		cancel()
+		return fstools.ToolResult{OK: true, Tool: "Stop"}
+	}}
+	cfg := newTestConfig(t, srv, append(fstools.ReadWriteTools(), stopTool))
+
+	res, err := Run(ctx, cfg, "do it")
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("err = %v, want ErrInterrupted", err)
+	}
+	if res.Iterations != 1 {
+		t.Errorf("iterations = %d, want 1", res.Iterations)
+	}
+	if !reflect.DeepEqual(res.FilesModified, []string{"a.txt"}) {
+		t.Errorf("files modified = %v", res.FilesModified)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.RepoRoot, "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("b.txt must not be written after interruption (stat err = %v)", err)
+	}
+
+	if len(res.Pending) != 3 {
+		t.Fatalf("pending outputs = %d, want 3", len(res.Pending))
+	}
+	if out := res.Pending[2]["output"].(string); !strings.Contains(out, "Not executed") {
+		t.Errorf("skipped call output = %s", out)
+	}
+
+	// Resuming delivers the pending outputs before the new prompt
+	srv.Push(fakeapi.Text("resumed"))
+	cfg.Pending = res.Pending
+	if _, err := Run(context.Background(), cfg, "continue"); err != nil {
+		t.Fatal(err)
+	}
+	input := srv.Requests()[1]["input"].([]interface{})
+	if len(input) != 4 || input[0].(map[string]interface{})["call_id"] != "a" || input[3].(map[string]interface{})["content"] != "continue" {
+		t.Errorf("resume input = %v", input)
+	}
+}
+
+func TestRunMaxItersKeepsPendingOutputs(t *testing.T) {
+	srv := fakeapi.New(fakeapi.Calls(fakeapi.ToolCall{CallID: "g", Name: "Glob", Arguments: `{"pattern":"*"}`}))
+	defer srv.Close()
+	cfg := newTestConfig(t, srv, fstools.ReadOnlyTools())
+	cfg.MaxIters = 1
+
+	res, err := Run(context.Background(), cfg, "look")
+	if err == nil || !strings.Contains(err.Error(), "MAX_ITERS=1") {
+		t.Fatalf("err = %v", err)
+	}
+	if len(res.Pending) != 1 || res.Pending[0]["call_id"] != "g" {
+		t.Errorf("pending = %v", res.Pending)
+	}
+}
+
+func TestRunRebuildsExpiredPreviousResponse(t *testing.T) {
+	srv := fakeapi.New(
+		fakeapi.Calls(fakeapi.ToolCall{CallID: "r", Name: "Read", Arguments: `{"path":"missing.txt"}`}),
+		fakeapi.Text("done"),
+	)
+	defer srv.Close()
+	srv.Expire("resp_old")
+
+	// A chain forked from a response the server has since dropped
+	cfg := newTestConfig(t, srv, fstools.ReadOnlyTools())
+	chain := api.NewChainAdapter(cfg.Provider)
+	id, state, err := api.ForkChain("resp_old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.Restore(id, state)
+	cfg.Provider, cfg.ConversationID = chain, id
+	cfg.Pending = []map[string]interface{}{{"type": "function_call_output", "call_id": "old", "output": "{}"}}
+	rebuilds := 0
+	cfg.Rebuild = func(ctx context.Context, reason string) (string, error) {
+		rebuilds++
+		return chain.CreateConversation(ctx, "system")
+	}
+
+	res, err := Run(context.Background(), cfg, "go on")
+	if err != nil || res.Text != "done" || res.Iterations != 2 || rebuilds != 1 {
+		t.Fatalf("res = %+v, err = %v, rebuilds = %d", res, err, rebuilds)
+	}
+	reqs := srv.Requests()
+	if reqs[0]["previous_response_id"] != "resp_old" {
+		t.Errorf("first request must continue the fork: %v", reqs[0])
+	}
+	retry := reqs[1]
+	if _, chained := retry["previous_response_id"]; chained {
+		t.Errorf("retry must start the new chain: %v", retry)
+	}
+	if input := retry["input"].([]interface{}); len(input) != 2 {
+		t.Errorf("retry input must be the system prompt and prompt only (pending outputs of the lost conversation dropped): %v", input)
+	}
+	if reqs[2]["previous_response_id"] != "resp_fake_2" {
+		t.Errorf("later requests must stay on the new chain: %v", reqs[2])
+	}
+
+	// A second 404 is not retried
+	cfg.Rebuild = func(ctx context.Context, reason string) (string, error) {
+		rebuilds++
+		id, state, _ := api.ForkChain("resp_old")
+		return id, chain.Restore(id, state)
+	}
+	if _, err := Run(context.Background(), cfg, "again"); err == nil || rebuilds != 2 || len(srv.Requests()) != 5 {
+		t.Errorf("err = %v, rebuilds = %d, requests = %d", err, rebuilds, len(srv.Requests()))
+	}
+}
+
+func TestRunCompactsConversation(t *testing.T) {
+	newRun := func(t *testing.T, srv *fakeapi.Server) (Config, *[]string) {
+		cfg := newTestConfig(t, srv, fstools.ReadOnlyTools())
+		os.WriteFile(filepath.Join(cfg.RepoRoot, "big.txt"), []byte("line of a big file\n"), 0644)
+		var reasons []string
+		cfg.Rebuild = func(ctx context.Context, reason string) (string, error) {
+			reasons = append(reasons, reason)
+			return "conv_compact", nil
+		}
+		return cfg, &reasons
+	}
+	checkContinuation := func(t *testing.T, req map[string]interface{}) {
+		t.Helper()
+		input := req["input"].([]interface{})
+		content, _ := input[0].(map[string]interface{})["content"].(string)
+		if req["conversation"] != "conv_compact" || len(input) != 1 ||
+			!strings.Contains(content, "[CONTEXT COMPACTED]") || !strings.Contains(content, "summarize big.txt") ||
+			!strings.Contains(content, `### Read {"path":"big.txt"}`) || !strings.Contains(content, "line of a big file") {
+			t.Errorf("continuation request = %v", req)
+		}
+	}
+
+	t.Run("after context-length error", func(t *testing.T) {
+		srv := fakeapi.New(
+			fakeapi.Calls(fakeapi.ToolCall{CallID: "r", Name: "Read", Arguments: `{"path":"big.txt"}`}),
+			fakeapi.Error(400, `{"error":{"message":"Your input exceeds the context window of this model.","code":"context_length_exceeded"}}`),
+			fakeapi.Text("summary done"),
+		)
+		defer srv.Close()
+		cfg, reasons := newRun(t, srv)
+
+		res, err := Run(context.Background(), cfg, "summarize big.txt")
+		if err != nil || res.Text != "summary done" || len(*reasons) != 1 || (*reasons)[0] != ReasonCompacted {
+			t.Fatalf("res = %+v, err = %v, reasons = %v", res, err, *reasons)
+		}
+		checkContinuation(t, srv.Requests()[2])
+	})
+
+	t.Run("ahead of threshold", func(t *testing.T) {
+		srv := fakeapi.New(
+			fakeapi.Calls(fakeapi.ToolCall{CallID: "r", Name: "Read", Arguments: `{"path":"big.txt"}`}).WithUsage(1000, 0, 10, 0),
+			fakeapi.Text("summary done"),
+		)
+		defer srv.Close()
+		cfg, reasons := newRun(t, srv)
+		cfg.Compaction.Threshold = 500
+
+		if _, err := Run(context.Background(), cfg, "summarize big.txt"); err != nil || len(*reasons) != 1 {
+			t.Fatalf("err = %v, reasons = %v", err, *reasons)
+		}
+		if reqs := srv.Requests(); len(reqs) != 2 {
+			t.Fatalf("compaction should not cost a failed request: %d requests", len(reqs))
+		}
+		checkContinuation(t, srv.Requests()[1])
+	})
+
+	t.Run("repeated error is not retried", func(t *testing.T) {
+		tooLong := fakeapi.Error(400, `{"error":{"message":"too long","code":"context_length_exceeded"}}`)
+		srv := fakeapi.New(tooLong, tooLong)
+		defer srv.Close()
+		cfg, reasons := newRun(t, srv)
+
+		if _, err := Run(context.Background(), cfg, "summarize big.txt"); err == nil || len(*reasons) != 1 {
+			t.Errorf("err = %v, reasons = %v", err, *reasons)
+		}
+	})
+
+	t.Run("truncate mode", func(t *testing.T) {
+		srv := fakeapi.New(fakeapi.Text("ok"))
+		defer srv.Close()
+		cfg, _ := newRun(t, srv)
+		cfg.Compaction.Mode = CompactTruncate
+
+		Run(context.Background(), cfg, "hi")
+		if srv.Requests()[0]["truncation"] != "auto" {
+			t.Error(`truncate mode must send "truncation": "auto"`)
+		}
+	})
+}
+
+func TestRunEncodesEveryOutputAsJSON(t *testing.T) {
+	srv := fakeapi.New(
+		fakeapi.Calls(
+			fakeapi.ToolCall{CallID: "bad", Name: "Read", Arguments: `{"path" "a.txt"}`},
+			fakeapi.ToolCall{CallID: "big", Name: "Grep", Arguments: `{"query":"needle"}`},
+		),
+		fakeapi.Text("done"),
+	)
+	defer srv.Close()
+	cfg := newTestConfig(t, srv, fstools.ReadOnlyTools())
+	cfg.ResultBudget = 4096
+	os.WriteFile(filepath.Join(cfg.RepoRoot, "hay.txt"), []byte(strings.Repeat("needle in the haystack\n", 500)), 0644)
+
+	if _, err := Run(context.Background(), cfg, "search"); err != nil {
+		t.Fatal(err)
+	}
+	outputs := srv.FunctionOutputs(1)
+
+	var bad fstools.ToolResult
+	if err := json.Unmarshal([]byte(outputs["bad"]), &bad); err != nil || bad.OK || !strings.Contains(bad.Error, `invalid character '"'`) {
+		t.Errorf("invalid-arguments output = %s (%v)", outputs["bad"], err)
+	}
+
+	var big fstools.ToolResult
+	if err := json.Unmarshal([]byte(outputs["big"]), &big); err != nil || len(outputs["big"]) > 4096 || big.Truncated == nil || big.Truncated.Omitted == 0 {
+		t.Errorf("oversized output (%d bytes) = %.200s (%v)", len(outputs["big"]), outputs["big"], err)
+	}
+}
+
+func TestRunParallelToolCalls(t *testing.T) {
+	srv := fakeapi.New(fakeapi.Calls(
+		fakeapi.ToolCall{CallID: "w1", Name: "Wait", Arguments: `{"path":"w1"}`},
+		fakeapi.ToolCall{CallID: "write", Name: "Write", Arguments: `{"path":"a.txt","content":"one"}`},
+		fakeapi.ToolCall{CallID: "w2", Name: "Wait", Arguments: `{"path":"w2"}`},
+		fakeapi.ToolCall{CallID: "edit", Name: "Edit", Arguments: `{"path":"a.txt","old_string":"one","new_string":"two"}`},
+		fakeapi.ToolCall{CallID: "read", Name: "Read", Arguments: `{"path":"./a.txt"}`},
+		fakeapi.ToolCall{CallID: "grep", Name: "Grep", Arguments: `{"query":"two"}`},
+	), fakeapi.Text("done"))
+	defer srv.Close()
+
+	// Each Wait returns only once both are running, proving they overlap.
+	// They name paths: path-less calls would wait for the writes.
+	var started sync.WaitGroup
+	started.Add(2)
+	waitTool := fstools.Tool{Name: "Wait", Run: func(repoRoot string, args fstools.Args) fstools.ToolResult {
+		started.Done()
+		done := make(chan struct{})
+		go func() { started.Wait(); close(done) }()
+		select {
+		case <-done:
+			return fstools.ToolResult{OK: true, Tool: "Wait"}
+		case <-time.After(5 * time.Second):
+			return fstools.ToolResult{OK: false, Tool: "Wait", Error: "ran serially"}
+		}
+	}}
+	cfg := newTestConfig(t, srv, append(fstools.ReadWriteTools(), waitTool))
+	cfg.ParallelToolCalls = true
+	cfg.MaxParallelTools = 4
+
+	res, err := Run(context.Background(), cfg, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.FilesModified, []string{"a.txt"}) {
+		t.Errorf("files modified = %v", res.FilesModified)
+	}
+	if data, _ := os.ReadFile(filepath.Join(cfg.RepoRoot, "a.txt")); string(data) != "two" {
+		t.Errorf("a.txt = %q, want the write then the edit applied in order", data)
+	}
+
+	// Outputs keep the model's order whatever order the calls finished in
+	var ids []string
+	for _, item := range srv.Requests()[1]["input"].([]interface{}) {
+		ids = append(ids, item.(map[string]interface{})["call_id"].(string))
+	}
+	if got := strings.Join(ids, " "); got != "w1 write w2 edit read grep" {
+		t.Errorf("output order = %s", got)
+	}
+	outputs := srv.FunctionOutputs(1)
+	for id, out := range outputs {
+		var result fstools.ToolResult
+		if err := json.Unmarshal([]byte(out), &result); err != nil || !result.OK {
+			t.Errorf("%s output = %s", id, out)
+		}
+	}
+	if !strings.Contains(outputs["read"], "two") || !strings.Contains(outputs["grep"], "a.txt:1:two") {
+		t.Errorf("read = %s\ngrep = %s", outputs["read"], outputs["grep"])
+	}
+}
+
+func TestGroupCallsOrdersReadsAfterWrites(t *testing.T) {
+	call := func(name, args string) api.FunctionCall {
+		return api.FunctionCall{Name: name, Arguments: args}
+	}
+	tools := fstools.ReadWriteTools()
+	tests := []struct {
+		name  string
+		calls []api.FunctionCall
+		want  [][]int
+	}{
+		{"reads only", []api.FunctionCall{
+			call("Read", `{"path":"a.txt"}`), call("Grep", `{"query":"x"}`), call("Glob", `{"pattern":"*"}`),
+		}, [][]int{{0}, {1}, {2}}},
+		{"same path", []api.FunctionCall{
+			call("Write", `{"path":"a.txt"}`), call("Read", `{"path":"b.txt"}`), call("Read", `{"path":"./a.txt"}`),
+		}, [][]int{{0, 2}, {1}}},
+		{"path-less reads follow every write", []api.FunctionCall{
+			call("Write", `{"path":"a.txt"}`), call("Read", `{"path":"c.txt"}`), call("Edit", `{"path":"b.txt"}`),
+			call("Grep", `{"query":"x"}`), call("Read", `{"path":"b.txt"}`), call("Glob", `{"pattern":"*"}`),
+		}, [][]int{{0, 2, 3, 4, 5}, {1}}},
+	}
+	for _, tt := range tests {
+		if got := groupCalls(tools, tt.calls); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: groups = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LoadProjectMemory loads CLAUDE.md and rules like Claude Code
+// Priority: user memory -> user rules -> project memory -> project rules
+func LoadProjectMemory(repoRoot string) string {
+	var sections []string
+	homeDir, _ := os.UserHomeDir()
+
+	// 1. User memory: ~/.claude/CLAUDE.md
+	if homeDir != "" {
+		userClaudePath := filepath.Join(homeDir, ".claude", "CLAUDE.md")
+		if data, err := os.ReadFile(userClaudePath); err == nil {
+			sections = append(sections, fmt.Sprintf("### %s (user memory)\n\n%s", userClaudePath, string(data)))
+		}
+
+		// 2. User rules: ~/.claude/rules/*.md
+		userRulesDir := filepath.Join(homeDir, ".claude", "rules")
+		if rules := loadRulesDir(userRulesDir, "user rules"); len(rules) > 0 {
+			sections = append(sections, rules...)
+		}
+	}
+
+	// 3. Project memory: .claude/CLAUDE.md or CLAUDE.md
+	projectClaudePaths := []string{
+		filepath.Join(repoRoot, ".claude", "CLAUDE.md"),
+		filepath.Join(repoRoot, "CLAUDE.md"),
+	}
+	for _, p := range projectClaudePaths {
+		if data, err := os.ReadFile(p); err == nil {
+			relPath, _ := filepath.Rel(repoRoot, p)
+			if relPath == "" {
+				relPath = p
+			}
+			sections = append(sections, fmt.Sprintf("### %s (project memory)\n\n%s", relPath, string(data)))
+			break // Only first found
+		}
+	}
+
+	// 4. Project rules: .claude/rules/*.md
+	projectRulesDir := filepath.Join(repoRoot, ".claude", "rules")
+	if rules := loadRulesDir(projectRulesDir, "project rules"); len(rules) > 0 {
+		sections = append(sections, rules...)
+	}
+
+	if len(sections) == 0 {
+		return ""
+	}
+
+	return strings.Join(sections, "\n\n---\n\n")
+}
+
+// loadRulesDir loads all .md files from a rules directory
+func loadRulesDir(rulesDir, ruleType string) []string {
+	var rules []string
+
+	entries, err := os.ReadDir(rulesDir)
+	if err != nil {
+		return rules
+	}
+
+	// Sort by filename (lower numbers = higher priority)
+	var mdFiles []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") {
+			mdFiles = append(mdFiles, entry.Name())
+		}
+	}
+	sort.Strings(mdFiles)
+
+	for _, name := range mdFiles {
+		path := filepath.Join(rulesDir, name)
+		if data, err := os.ReadFile(path); err == nil {
+			rules = append(rules, fmt.Sprintf("### %s (%s)\n\n%s", name, ruleType, string(data)))
+		}
+	}
+
+	return rules
+}
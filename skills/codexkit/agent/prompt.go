@@ -0,0 +1,24 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadPrompt loads a prompt template that ships next to the binary and
+// substitutes {key} placeholders
+func LoadPrompt(fileName string, vars map[string]string) (string, error) {
+	scriptDir, _ := filepath.Abs(filepath.Dir(os.Args[0]))
+	template, err := os.ReadFile(filepath.Join(scriptDir, fileName))
+	if err != nil {
+		return "", err
+	}
+
+	// Single pass so substituted values are never re-expanded
+	pairs := make([]string, 0, len(vars)*2)
+	for key, val := range vars {
+		pairs = append(pairs, "{"+key+"}", val)
+	}
+	return strings.NewReplacer(pairs...).Replace(string(template)), nil
+}
@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"context"
+
+	"codexkit/api"
+	"codexkit/fstools"
+	"codexkit/session"
+)
+
+// historySummaryLimit caps the transcript summary seeded into a rebuilt conversation
+const historySummaryLimit = 24 << 10
+
+// RebuildConversation creates a conversation to replace one the server no
+// longer has or that outgrew the context window (reason says which; see
+// Config.Rebuild). It is seeded with systemPrompt plus a compacted summary
+// of the session's transcript file, and the seeded prompt is recorded in t.
+func RebuildConversation(ctx context.Context, provider api.Provider, systemPrompt, reason, transcriptFile string, t *session.Transcript) (string, error) {
+	// Best effort: a missing or damaged transcript only shortens the summary
+	events, _ := session.ReadTranscript(transcriptFile)
+	if summary := session.Summarize(events, historySummaryLimit); summary != "" {
+		systemPrompt += "\n\n# Prior Session History\n" + reason +
+			" This summary was rebuilt from the local transcript; re-read files before relying on its details.\n\n" +
+			summary
+	}
+
+	id, err := provider.CreateConversation(ctx, systemPrompt)
+	if err != nil {
+		return "", err
+	}
+	t.Record(session.Event{Type: session.EventSystem, Text: fstools.RedactSecrets(systemPrompt)})
+	return id, nil
+}
+
+// SaveConversation copies a client-side conversation (chain or local mode)
+// into sess so a later run can restore it; server-side conversations need
+// nothing saved
+func SaveConversation(provider api.Provider, sess *session.Data) error {
+	state, err := api.SaveState(provider, sess.ConversationID)
+	if err != nil {
+		return err
+	}
+	sess.ConversationState = state
+	return nil
+}
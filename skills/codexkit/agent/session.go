@@ -0,0 +1,219 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"codexkit/api"
+	"codexkit/fstools"
+	"codexkit/session"
+)
+
+// defaultMaxIters is the MAX_ITERS used when the environment sets none
+const defaultMaxIters = 50
+
+// SessionSpec describes one CLI run of the tool loop on a named session.
+// Everything else (provider, model, limits) comes from the environment.
+type SessionSpec struct {
+	Name     string // Session name, already checked with session.ValidName
+	Kind     string // Recorded in the session file ("task", "review")
+	Label    string // How messages refer to the session (default "Session <name>")
+	Dir      string // Sessions directory, created if missing
+	RepoRoot string
+	Prompt   string // User prompt for this run, as recorded in the session
+	Message  string // What the model is sent (default Prompt)
+
+	// SystemPrompt builds the prompt that seeds a new or rebuilt conversation
+	SystemPrompt func() string
+
+	// Describe, if set, records run metadata beyond Kind and Prompt
+	Describe func(sess *session.Data)
+
+	Tools             fstools.Registry
+	ParallelToolCalls bool
+	TraceToolCalls    bool
+	ReasoningEffort   string // Used when REASONING_EFFORT is unset
+
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// RunSession runs spec's prompt on its session: it holds the session lock,
+// resumes or creates the conversation, saves the session before and after
+// the run and reports the outcome. It returns the run's result and the
+// process exit code: 0 done, 2 usage/config error, 3 blocked or
+// ExitInterrupted.
+func RunSession(ctx context.Context, spec SessionSpec) (Result, int) {
+	stdout, stderr := spec.Stdout, spec.Stderr
+	label := cmp.Or(spec.Label, "Session "+spec.Name)
+
+	// Environment
+	provider, err := NewProviderFromEnv(stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return Result{}, 2
+	}
+
+	prices, err := PricesFromEnv()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return Result{}, 2
+	}
+
+	model := GetEnv("OPENAI_MODEL", "gpt-5.2-codex")
+	reasoningEffort := GetEnv("REASONING_EFFORT", spec.ReasoningEffort)
+	budget, err := BudgetFromEnv(model, prices)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return Result{}, 2
+	}
+	compaction, err := CompactionFromEnv()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return Result{}, 2
+	}
+
+	// Session management
+	if err := os.MkdirAll(spec.Dir, 0755); err != nil {
+		fmt.Fprintf(stderr, "Failed to create sessions dir: %v\n", err)
+		return Result{}, 2
+	}
+	sessionFile := session.File(spec.Dir, spec.Name)
+	transcriptFile := session.TranscriptFile(spec.Dir, spec.Name)
+
+	// One run per session at a time: concurrent runs would interleave tool
+	// calls into the same conversation
+	lock, err := session.Lock(ctx, spec.Dir, spec.Name, GetEnvDuration("SESSION_LOCK_WAIT", 0))
+	if errors.Is(err, session.ErrLocked) {
+		fmt.Fprintf(stderr, "[BLOCKED] %s: %v; wait for it to finish or set SESSION_LOCK_WAIT\n", label, err)
+		return Result{}, 3
+	}
+	if ctx.Err() != nil {
+		ReportInterrupted(stdout, Result{})
+		return Result{}, ExitInterrupted
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to lock session: %v\n", err)
+		return Result{}, 2
+	}
+	defer lock.Unlock()
+
+	// Load or create conversation
+	sess, loadErr := session.Load(sessionFile)
+
+	// Every turn is appended to a redacted JSONL transcript next to the session file
+	transcript, err := session.OpenTranscript(transcriptFile, sess.Runs+1)
+	if err != nil {
+		fmt.Fprintf(stderr, "Warning: failed to open transcript: %v\n", err)
+	}
+	defer transcript.Close()
+
+	// A session forked with --from-response holds a chain; server mode
+	// continues it with previous_response_id the way chain mode does
+	if client, ok := provider.(*api.Client); ok && api.IsChainID(sess.ConversationID) {
+		provider = api.NewChainAdapter(client)
+	}
+
+	// Client-side modes (chain, local) keep the conversation in the session file
+	if err := api.RestoreState(provider, sess.ConversationID, sess.ConversationState); err != nil {
+		fmt.Fprintf(stderr, "Warning: failed to restore conversation: %v\n", err)
+	}
+
+	// save writes the session file, warning on failure; the run goes on
+	save := func() {
+		if err := SaveConversation(provider, &sess); err != nil {
+			fmt.Fprintf(stderr, "Warning: failed to save conversation: %v\n", err)
+		}
+		if err := session.Save(sessionFile, &sess); err != nil {
+			fmt.Fprintf(stderr, "Warning: failed to save session: %v\n", err)
+		}
+	}
+
+	if loadErr != nil || sess.ConversationID == "" || !api.CanResume(provider, sess.ConversationID) {
+		systemPrompt := spec.SystemPrompt()
+		conversationID, err := provider.CreateConversation(ctx, systemPrompt)
+		if ctx.Err() != nil {
+			ReportInterrupted(stdout, Result{})
+			return Result{}, ExitInterrupted
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "[BLOCKED] Failed to create conversation: %v\n", err)
+			return Result{}, 3
+		}
+		// Usage totals carry over; pending outputs belonged to the old conversation
+		sess.ConversationID = conversationID
+		sess.PendingOutputs = nil
+		transcript.Record(session.Event{Type: session.EventSystem, Text: fstools.RedactSecrets(systemPrompt)})
+	}
+
+	// Record what this run is about before starting it
+	sess.Kind = spec.Kind
+	sess.Model = model
+	sess.ReasoningEffort = reasoningEffort
+	sess.RepoRoot = spec.RepoRoot
+	sess.Prompt = spec.Prompt
+	if spec.Describe != nil {
+		spec.Describe(&sess)
+	}
+	sess.Status = session.StatusRunning
+	sess.Error = ""
+	save()
+
+	res, err := Run(ctx, Config{
+		Provider:          provider,
+		Model:             model,
+		ReasoningEffort:   reasoningEffort,
+		ConversationID:    sess.ConversationID,
+		RepoRoot:          spec.RepoRoot,
+		MaxIters:          GetEnvInt("MAX_ITERS", defaultMaxIters),
+		ResultBudget:      GetEnvInt("MAX_RESULT_BYTES", fstools.DefaultResultBudget),
+		Tools:             spec.Tools,
+		ParallelToolCalls: spec.ParallelToolCalls,
+		MaxParallelTools:  GetEnvInt("MAX_PARALLEL_TOOLS", defaultMaxParallelTools),
+		Stream:            GetEnvBool("OPENAI_STREAM", true),
+		Budget:            budget,
+		Compaction:        compaction,
+		Transcript:        transcript,
+		TraceToolCalls:    spec.TraceToolCalls,
+		Pending:           sess.PendingOutputs,
+		Stdout:            stdout,
+		Stderr:            stderr,
+		Rebuild: func(ctx context.Context, reason string) (string, error) {
+			// Start over from local history (expired or compacted conversation)
+			id, err := RebuildConversation(ctx, provider, spec.SystemPrompt(), reason, transcriptFile, transcript)
+			if err != nil {
+				return "", err
+			}
+			sess.ConversationID, sess.PendingOutputs = id, nil
+			save()
+			return id, nil
+		},
+	}, cmp.Or(spec.Message, spec.Prompt))
+
+	if err := transcript.Close(); err != nil {
+		fmt.Fprintf(stderr, "Warning: failed to write transcript: %v\n", err)
+	}
+
+	// Persist the outcome and undelivered tool outputs so the session can be resumed
+	cost, priced := EstimateCost(prices, model, res.Usage)
+	RecordRun(&sess, res, err, cost)
+	save()
+	ReportUsage(stderr, model, res.Usage, cost, priced, sess.Usage, sess.CostUSD)
+
+	switch {
+	case errors.Is(err, ErrInterrupted):
+		ReportInterrupted(stdout, res)
+		return res, ExitInterrupted
+	case errors.Is(err, ErrBudgetExceeded):
+		ReportBudgetExceeded(stdout, res, err)
+		return res, 3
+	case err != nil:
+		fmt.Fprintf(stderr, "[BLOCKED] %v\n", err)
+		return res, 3
+	}
+	return res, 0
+}
@@ -0,0 +1,41 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ExitInterrupted is the exit code after SIGINT/SIGTERM (128 + SIGINT)
+const ExitInterrupted = 130
+
+// SignalContext returns a context cancelled by the first SIGINT or SIGTERM.
+// A second signal terminates the process immediately.
+func SignalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop() // Restore default handling for the next signal
+	}()
+	return ctx, stop
+}
+
+// ReportInterrupted prints the [INTERRUPTED] marker with what was done
+func ReportInterrupted(w io.Writer, res Result) {
+	fmt.Fprintf(w, "\n[INTERRUPTED] Stopped after %d iterations\n", res.Iterations)
+	reportFilesModified(w, res.FilesModified)
+}
+
+// reportFilesModified prints a [FILES_MODIFIED] list (nothing when empty)
+func reportFilesModified(w io.Writer, files []string) {
+	if len(files) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "[FILES_MODIFIED]")
+	for _, path := range files {
+		fmt.Fprintf(w, "- %s\n", path)
+	}
+}
@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"time"
+
+	"codexkit/api"
+	"codexkit/fstools"
+	"codexkit/session"
+)
+
+// recordResponse logs a model turn: timing and usage, text, then each call
+func recordResponse(t *session.Transcript, iteration int, resp map[string]interface{}, took time.Duration, usage api.Usage, hasUsage bool, calls []api.FunctionCall, text string) {
+	if t == nil {
+		return
+	}
+	ev := session.Event{Type: session.EventResponse, Iteration: iteration, DurationMS: took.Milliseconds()}
+	ev.ResponseID, _ = resp["id"].(string)
+	if hasUsage {
+		ev.Usage = &usage
+	}
+	t.Record(ev)
+
+	if text != "" {
+		t.Record(session.Event{Type: session.EventText, Iteration: iteration, Text: fstools.RedactSecrets(text)})
+	}
+	for _, call := range calls {
+		t.Record(session.Event{
+			Type:      session.EventFunctionCall,
+			Iteration: iteration,
+			CallID:    call.CallID,
+			Name:      call.Name,
+			Arguments: fstools.RedactArguments(call.Arguments),
+		})
+	}
+}
+
+// recordToolResult logs the output returned to the model for one call
+func recordToolResult(t *session.Transcript, iteration int, call api.FunctionCall, output []byte, took time.Duration) {
+	t.Record(session.Event{
+		Type:       session.EventToolResult,
+		Iteration:  iteration,
+		CallID:     call.CallID,
+		Name:       call.Name,
+		Result:     []byte(fstools.RedactSecrets(string(output))),
+		DurationMS: took.Milliseconds(),
+	})
+}
@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"codexkit/api"
+)
+
+// PricesFromEnv returns the model price table, with PRICE_TABLE (inline
+// JSON or the path of a JSON file) layered over api.DefaultPrices
+func PricesFromEnv() (api.PriceTable, error) {
+	spec := strings.TrimSpace(os.Getenv("PRICE_TABLE"))
+	if spec == "" {
+		return api.DefaultPrices(), nil
+	}
+	data := []byte(spec)
+	if !strings.HasPrefix(spec, "{") {
+		var err error
+		if data, err = os.ReadFile(spec); err != nil {
+			return nil, fmt.Errorf("failed to read PRICE_TABLE: %w", err)
+		}
+	}
+	return api.ParsePriceTable(data)
+}
+
+// EstimateCost prices u for model; ok is false when the model is not in prices
+func EstimateCost(prices api.PriceTable, model string, u api.Usage) (float64, bool) {
+	p, ok := prices.Lookup(model)
+	if !ok {
+		return 0, false
+	}
+	return p.Cost(u), true
+}
+
+// ReportUsage prints [USAGE] lines for this run and the session totals.
+// Nothing is printed when the server never reported usage.
+func ReportUsage(w io.Writer, model string, run api.Usage, runCost float64, priced bool, total api.Usage, totalCost float64) {
+	if run.Requests == 0 && total.Requests == 0 {
+		return
+	}
+	cost := fmt.Sprintf("est. $%.4f", runCost)
+	if !priced {
+		cost = fmt.Sprintf("cost unknown (no price for %s; set PRICE_TABLE)", model)
+	}
+	fmt.Fprintf(w, "[USAGE] This run: %s, %s\n", formatUsage(run), cost)
+	fmt.Fprintf(w, "[USAGE] Session total: %s, est. $%.4f\n", formatUsage(total), totalCost)
+}
+
+func formatUsage(u api.Usage) string {
+	return fmt.Sprintf("%d requests, %d input tokens (%d cached), %d output tokens (%d reasoning)",
+		u.Requests, u.InputTokens, u.CachedTokens, u.OutputTokens, u.ReasoningTokens)
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ChainAdapter emulates the Conversations API with previous_response_id:
+// the backend stores each response and the next request continues from the
+// last one, so only new items are sent. Items that have no response yet
+// (the system prompt, replayed history) are sent with the next request.
+type ChainAdapter struct {
+	Backend Provider // Only CreateResponse and StreamResponse are used
+
+	mu     sync.Mutex
+	chains map[string]*chainState
+}
+
+// chainState is what a chain needs to continue; it is also its saved State
+type chainState struct {
+	PreviousResponseID string        `json:"previous_response_id,omitempty"`
+	Seed               []interface{} `json:"seed,omitempty"` // Sent before the next input
+}
+
+// NewChainAdapter wraps backend with previous_response_id chaining
+func NewChainAdapter(backend Provider) *ChainAdapter {
+	return &ChainAdapter{Backend: backend, chains: map[string]*chainState{}}
+}
+
+// ForkChain returns the ID and saved State of a new chain that continues
+// from responseID; a ChainAdapter picks it up with Restore
+func ForkChain(responseID string) (string, json.RawMessage, error) {
+	id, err := localID(chainIDPrefix)
+	if err != nil {
+		return "", nil, err
+	}
+	state, err := json.Marshal(chainState{PreviousResponseID: responseID})
+	if err != nil {
+		return "", nil, err
+	}
+	return id, state, nil
+}
+
+// IsChainID reports whether id names a chain kept by a ChainAdapter
+func IsChainID(id string) bool {
+	return strings.HasPrefix(id, chainIDPrefix)
+}
+
+// CreateConversation starts a chain whose first request carries the system prompt
+func (c *ChainAdapter) CreateConversation(ctx context.Context, systemPrompt string) (string, error) {
+	id, err := localID(chainIDPrefix)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chains[id] = &chainState{Seed: []interface{}{
+		map[string]interface{}{
+			"role":    "developer",
+			"content": systemPrompt,
+		},
+	}}
+	return id, nil
+}
+
+// AddItems queues items to be sent with the chain's next request
+func (c *ChainAdapter) AddItems(ctx context.Context, conversationID string, items []interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	chain, ok := c.chains[conversationID]
+	if !ok {
+		return fmt.Errorf("%w: chain %q", ErrConversationNotFound, conversationID)
+	}
+	chain.Seed = append(chain.Seed, items...)
+	return nil
+}
+
+// CanResume reports whether the chain is held by this adapter
+func (c *ChainAdapter) CanResume(conversationID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.chains[conversationID]
+	return ok
+}
+
+// State returns the chain's last response ID and unsent items as JSON
+func (c *ChainAdapter) State(conversationID string) (json.RawMessage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	chain, ok := c.chains[conversationID]
+	if !ok {
+		return nil, fmt.Errorf("%w: chain %q", ErrConversationNotFound, conversationID)
+	}
+	return json.Marshal(chain)
+}
+
+// Restore loads a chain saved by State
+func (c *ChainAdapter) Restore(conversationID string, state json.RawMessage) error {
+	var chain chainState
+	if err := json.Unmarshal(state, &chain); err != nil {
+		return fmt.Errorf("chain %q: %w", conversationID, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chains[conversationID] = &chain
+	return nil
+}
+
+// CreateResponse continues the chain from its last response
+func (c *ChainAdapter) CreateResponse(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
+	id, full, err := c.expand(payload)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Backend.CreateResponse(ctx, full)
+	if err != nil {
+		return nil, err
+	}
+	c.commit(id, resp)
+	return resp, nil
+}
+
+// StreamResponse is CreateResponse with streamed output text
+func (c *ChainAdapter) StreamResponse(ctx context.Context, payload map[string]interface{}, onText func(string)) (map[string]interface{}, error) {
+	id, full, err := c.expand(payload)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Backend.StreamResponse(ctx, full, onText)
+	if err != nil {
+		return nil, err
+	}
+	c.commit(id, resp)
+	return resp, nil
+}
+
+// expand replaces the conversation reference with previous_response_id
+// and prepends queued items to the input
+func (c *ChainAdapter) expand(payload map[string]interface{}) (string, map[string]interface{}, error) {
+	id, _ := payload["conversation"].(string)
+
+	c.mu.Lock()
+	chain, ok := c.chains[id]
+	var previous string
+	var seed []interface{}
+	if ok {
+		previous, seed = chain.PreviousResponseID, chain.Seed
+	}
+	c.mu.Unlock()
+	if !ok {
+		return "", nil, fmt.Errorf("%w: chain %q", ErrConversationNotFound, id)
+	}
+
+	full := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		if k != "conversation" {
+			full[k] = v
+		}
+	}
+	if previous != "" {
+		full["previous_response_id"] = previous
+	}
+	input := inputItems(payload["input"])
+	items := make([]interface{}, 0, len(seed)+len(input))
+	items = append(items, seed...)
+	items = append(items, input...)
+	full["input"] = items
+
+	return id, full, nil
+}
+
+// commit moves the chain to the new response; queued items were delivered
+func (c *ChainAdapter) commit(id string, resp map[string]interface{}) {
+	responseID, _ := resp["id"].(string)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if chain := c.chains[id]; chain != nil && responseID != "" {
+		chain.PreviousResponseID = responseID
+		chain.Seed = nil
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"testing"
+)
+
+func TestChainAdapterChainsResponses(t *testing.T) {
+	ctx := context.Background()
+	backend := &recordingBackend{}
+	adapter := NewChainAdapter(backend)
+
+	id, err := adapter.CreateConversation(ctx, "system prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter.AddItems(ctx, id, []interface{}{map[string]interface{}{"role": "user", "content": "replayed"}})
+
+	send := func(text string) map[string]interface{} {
+		t.Helper()
+		if _, err := adapter.CreateResponse(ctx, map[string]interface{}{
+			"conversation": id,
+			"input":        []map[string]interface{}{{"role": "user", "content": text}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return backend.payloads[len(backend.payloads)-1]
+	}
+
+	first := send("first")
+	if _, ok := first["previous_response_id"]; ok || len(first["input"].([]interface{})) != 3 {
+		t.Errorf("first request = %v", first)
+	}
+
+	// State survives a new adapter (a new process)
+	state, err := adapter.State(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter = NewChainAdapter(backend)
+	if CanResume(adapter, id) {
+		t.Fatal("fresh adapter cannot know the chain")
+	}
+	if err := RestoreState(adapter, id, state); err != nil || !CanResume(adapter, id) {
+		t.Fatalf("restore: %v", err)
+	}
+
+	second := send("second")
+	if second["previous_response_id"] != "resp_1" || len(second["input"].([]interface{})) != 1 {
+		t.Errorf("second request = %v", second)
+	}
+	if _, ok := second["conversation"]; ok {
+		t.Error("conversation must not be forwarded to the backend")
+	}
+}
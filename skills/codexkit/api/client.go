@@ -0,0 +1,355 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the OpenAI API endpoint used when no base URL is configured
+const DefaultBaseURL = "https://api.openai.com/v1"
+
+// Client talks to the OpenAI Conversations and Responses APIs, or to any
+// server exposing the same endpoints under BaseURL
+type Client struct {
+	APIKey     string
+	BaseURL    string     // Defaults to DefaultBaseURL
+	AuthHeader string     // "Authorization" sends "Bearer <key>"; any other header (e.g. Azure's "api-key") sends the raw key
+	Query      url.Values // Extra query parameters on every request (e.g. Azure api-version)
+	HTTPClient *http.Client
+	Retry      RetryPolicy
+}
+
+// FunctionCall is a function_call item emitted by the model
+type FunctionCall struct {
+	CallID    string
+	Name      string
+	Arguments string
+}
+
+// NewClient creates a client with a generous timeout for long reasoning runs
+func NewClient(apiKey string) *Client {
+	return &Client{
+		APIKey:     apiKey,
+		BaseURL:    DefaultBaseURL,
+		AuthHeader: "Authorization",
+		HTTPClient: &http.Client{Timeout: 30 * time.Minute}, // 30 min max for deep analysis
+		Retry:      DefaultRetryPolicy(),
+	}
+}
+
+// CreateConversation creates a new OpenAI conversation
+func (c *Client) CreateConversation(ctx context.Context, systemPrompt string) (string, error) {
+	payload := map[string]interface{}{
+		"items": []map[string]interface{}{
+			{
+				"role":    "developer",
+				"content": systemPrompt,
+			},
+		},
+	}
+
+	body, err := c.post(ctx, "/conversations", payload)
+	if err != nil {
+		return "", err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", err
+	}
+
+	conversationID, ok := result["id"].(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected response: %s", string(body))
+	}
+
+	return conversationID, nil
+}
+
+// maxItemsPerRequest is the Conversations API limit on items per create/append call
+const maxItemsPerRequest = 20
+
+// AddItems appends items to a stored conversation in batches
+func (c *Client) AddItems(ctx context.Context, conversationID string, items []interface{}) error {
+	for start := 0; start < len(items); start += maxItemsPerRequest {
+		batch := items[start:min(start+maxItemsPerRequest, len(items))]
+		path := "/conversations/" + url.PathEscape(conversationID) + "/items"
+		if _, err := c.post(ctx, path, map[string]interface{}{"items": batch}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteConversation deletes a stored conversation; one that is already
+// gone counts as deleted
+func (c *Client) DeleteConversation(ctx context.Context, conversationID string) error {
+	resp, err := c.send(ctx, "DELETE", "/conversations/"+url.PathEscape(conversationID), nil, "")
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// CreateResponse makes HTTP request to Responses API
+func (c *Client) CreateResponse(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
+	body, err := c.post(ctx, "/responses", payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// send issues a request with an optional JSON payload, retrying transient
+// failures per c.Retry, and returns a 200 response whose body the caller
+// must close. Requests that change a conversation are only resent after a
+// failure that may have reached the server if the conversation shows no
+// sign of them.
+func (c *Client) send(ctx context.Context, method, path string, payload map[string]interface{}, accept string) (*http.Response, error) {
+	var data []byte
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	var resp *http.Response
+	err := c.Retry.retry(ctx, method+" "+path, func() error {
+		var body io.Reader
+		if data != nil {
+			body = bytes.NewReader(data)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.endpoint(path), body)
+		if err != nil {
+			return err
+		}
+
+		c.authorize(req)
+		if data != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		r, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return err
+		}
+
+		if r.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(r.Body)
+			r.Body.Close()
+			return &HTTPError{StatusCode: r.StatusCode, Body: string(body[:min(2000, len(body))]), Header: r.Header}
+		}
+
+		resp = r
+		return nil
+	}, c.verifyUnapplied(ctx, method, path, payload))
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// verifyUnapplied returns the check retry runs before resending a request
+// that may have reached the server, or nil when sending it twice is harmless
+func (c *Client) verifyUnapplied(ctx context.Context, method, path string, payload map[string]interface{}) func() error {
+	if method != "POST" {
+		return nil // DELETE counts an already deleted conversation as success
+	}
+
+	var conversationID string
+	var items interface{}
+	switch {
+	case path == "/responses":
+		conversationID, _ = payload["conversation"].(string)
+		if conversationID == "" {
+			return nil // Nothing refers to a duplicate stateless response
+		}
+		items = payload["input"]
+	case path == "/conversations":
+		return nil // A duplicate only leaves an unused conversation behind
+	case strings.HasPrefix(path, "/conversations/") && strings.HasSuffix(path, "/items"):
+		conversationID, _ = url.PathUnescape(strings.TrimSuffix(strings.TrimPrefix(path, "/conversations/"), "/items"))
+		items = payload["items"]
+	default:
+		return func() error { return fmt.Errorf("%s %s is not safe to repeat", method, path) }
+	}
+	return func() error { return c.checkNotApplied(ctx, conversationID, items) }
+}
+
+// maxVerifyItems is how many of a conversation's newest items are searched
+// for a request's input
+const maxVerifyItems = 100
+
+// checkNotApplied fails if any of items already is among the newest items
+// of a conversation, or if that cannot be told
+func (c *Client) checkNotApplied(ctx context.Context, conversationID string, items interface{}) error {
+	var sent []map[string]interface{}
+	if text, ok := items.(string); ok {
+		sent = append(sent, map[string]interface{}{"role": "user", "content": text})
+	} else if data, err := json.Marshal(items); err != nil || json.Unmarshal(data, &sent) != nil {
+		return fmt.Errorf("cannot check conversation %s for the request's items", conversationID)
+	}
+	keys := map[string]bool{}
+	for _, item := range sent {
+		if key := itemKey(item); key != "" {
+			keys[key] = true
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("cannot check conversation %s for the request's items", conversationID)
+	}
+
+	path := fmt.Sprintf("/conversations/%s/items?order=desc&limit=%d", url.PathEscape(conversationID), maxVerifyItems)
+	resp, err := c.send(ctx, "GET", path, nil, "")
+	if err != nil {
+		return fmt.Errorf("checking conversation %s: %w", conversationID, err)
+	}
+	defer resp.Body.Close()
+	var list struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return fmt.Errorf("checking conversation %s: %w", conversationID, err)
+	}
+	for _, item := range list.Data {
+		if keys[itemKey(item)] {
+			return fmt.Errorf("conversation %s already holds the request's items", conversationID)
+		}
+	}
+	return nil
+}
+
+// itemKey identifies a conversation item both as sent and as stored: tool
+// calls and outputs by call_id, messages by role and text
+func itemKey(item map[string]interface{}) string {
+	if callID, _ := item["call_id"].(string); callID != "" {
+		return fmt.Sprint(item["type"], " ", callID)
+	}
+	role, _ := item["role"].(string)
+	if role == "" {
+		return ""
+	}
+	var text strings.Builder
+	switch content := item["content"].(type) {
+	case string:
+		text.WriteString(content)
+	case []interface{}:
+		for _, part := range content {
+			if p, ok := part.(map[string]interface{}); ok {
+				s, _ := p["text"].(string)
+				text.WriteString(s)
+			}
+		}
+	}
+	return role + " " + text.String()
+}
+
+// endpoint joins BaseURL, path and any configured query parameters
+func (c *Client) endpoint(path string) string {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	u := strings.TrimRight(base, "/") + path
+	if len(c.Query) > 0 {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		u += sep + c.Query.Encode()
+	}
+	return u
+}
+
+// authorize sets the API key header (none for keyless local servers)
+func (c *Client) authorize(req *http.Request) {
+	if c.APIKey == "" {
+		return
+	}
+	switch c.AuthHeader {
+	case "", "Authorization":
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	default:
+		req.Header.Set(c.AuthHeader, c.APIKey)
+	}
+}
+
+// post sends a JSON payload and returns the body of a 200 response
+func (c *Client) post(ctx context.Context, path string, payload map[string]interface{}) ([]byte, error) {
+	resp, err := c.send(ctx, "POST", path, payload, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+// ExtractCallsAndText parses response output
+func ExtractCallsAndText(resp map[string]interface{}) ([]FunctionCall, string) {
+	calls := []FunctionCall{}
+	texts := []string{}
+
+	output, ok := resp["output"].([]interface{})
+	if !ok {
+		return calls, ""
+	}
+
+	for _, item := range output {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		itemType, _ := itemMap["type"].(string)
+
+		if itemType == "function_call" {
+			call := FunctionCall{}
+			call.CallID, _ = itemMap["call_id"].(string)
+			call.Name, _ = itemMap["name"].(string)
+			call.Arguments, _ = itemMap["arguments"].(string)
+			calls = append(calls, call)
+		} else if itemType == "message" {
+			content, ok := itemMap["content"].([]interface{})
+			if !ok {
+				continue
+			}
+			for _, c := range content {
+				cMap, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if cMap["type"] == "output_text" {
+					if text, ok := cMap["text"].(string); ok {
+						texts = append(texts, text)
+					}
+				}
+			}
+		}
+	}
+
+	return calls, strings.Join(texts, "")
+}
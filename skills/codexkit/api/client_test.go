@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"codexkit/fakeapi"
+)
+
+func TestClientResendsOnlyUnappliedRequests(t *testing.T) {
+	srv := fakeapi.New(
+		fakeapi.Error(500, `{"error":{"message":"upstream exploded"}}`),
+		fakeapi.Text("First\n"),
+		fakeapi.Turn{Drop: true},
+		fakeapi.Text("Never sent\n"),
+	)
+	defer srv.Close()
+
+	client := NewClient("")
+	client.BaseURL = srv.URL
+	client.Retry = RetryPolicy{MaxAttempts: 3, MaxWait: time.Second, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	ctx := context.Background()
+
+	conv, err := client.CreateConversation(ctx, "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ask := func(text string) error {
+		_, err := client.CreateResponse(ctx, map[string]interface{}{
+			"conversation": conv,
+			"input":        []interface{}{map[string]interface{}{"role": "user", "content": text}},
+		})
+		return err
+	}
+
+	// The 500 left the conversation untouched, so the request is resent
+	if err := ask("Question one"); err != nil {
+		t.Fatalf("resent request failed: %v", err)
+	}
+	if n := len(srv.Requests()); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+
+	// The dropped connection stored the input: resending would repeat it
+	err = ask("Question two")
+	if err == nil || !strings.Contains(err.Error(), "already holds the request's items") {
+		t.Fatalf("err = %v, want the conversation check to stop the retry", err)
+	}
+	if n := len(srv.Requests()); n != 3 {
+		t.Errorf("requests = %d, want 3", n)
+	}
+}
+
+func TestClientRetriesConversationCreation(t *testing.T) {
+	srv := fakeapi.New()
+	defer srv.Close()
+	srv.FailConversations(fakeapi.Error(500, `{"error":{"message":"upstream exploded"}}`))
+
+	client := NewClient("")
+	client.BaseURL = srv.URL
+	client.Retry = RetryPolicy{MaxAttempts: 3, MaxWait: time.Second, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	conv, err := client.CreateConversation(context.Background(), "system")
+	if err != nil {
+		t.Fatalf("retried creation failed: %v", err)
+	}
+	if convs := srv.Conversations(); len(convs) != 1 || convs[0] != conv {
+		t.Errorf("conversations = %v, want [%s]", convs, conv)
+	}
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// HistoryAdapter emulates the Conversations API for backends that only
+// implement /responses: the conversation is kept client-side and replayed
+// as input on every call, so the agent loop runs unchanged
+type HistoryAdapter struct {
+	Backend Provider // Only CreateResponse and StreamResponse are used
+
+	// NoStore sends store=false so the backend retains nothing (zero data
+	// retention). Reasoning is requested in encrypted form so it can be
+	// replayed, and item IDs are dropped from the history since the server
+	// keeps no items they could refer to.
+	NoStore bool
+
+	mu        sync.Mutex
+	histories map[string][]interface{}
+}
+
+// NewHistoryAdapter wraps backend with client-side conversation history
+func NewHistoryAdapter(backend Provider) *HistoryAdapter {
+	return &HistoryAdapter{Backend: backend, histories: map[string][]interface{}{}}
+}
+
+// CreateConversation starts a local conversation seeded with the system prompt
+func (h *HistoryAdapter) CreateConversation(ctx context.Context, systemPrompt string) (string, error) {
+	id, err := localID(localIDPrefix)
+	if err != nil {
+		return "", err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.histories[id] = []interface{}{
+		map[string]interface{}{
+			"role":    "developer",
+			"content": systemPrompt,
+		},
+	}
+	return id, nil
+}
+
+// AddItems appends items to a local conversation's history
+func (h *HistoryAdapter) AddItems(ctx context.Context, conversationID string, items []interface{}) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	history, ok := h.histories[conversationID]
+	if !ok {
+		return fmt.Errorf("%w: local conversation %q", ErrConversationNotFound, conversationID)
+	}
+	h.histories[conversationID] = append(history, items...)
+	return nil
+}
+
+// CanResume reports whether the conversation's history is held by this adapter
+func (h *HistoryAdapter) CanResume(conversationID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.histories[conversationID]
+	return ok
+}
+
+// State returns the conversation's history as JSON
+func (h *HistoryAdapter) State(conversationID string) (json.RawMessage, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	history, ok := h.histories[conversationID]
+	if !ok {
+		return nil, fmt.Errorf("%w: local conversation %q", ErrConversationNotFound, conversationID)
+	}
+	return json.Marshal(history)
+}
+
+// Restore loads a history saved by State
+func (h *HistoryAdapter) Restore(conversationID string, state json.RawMessage) error {
+	var history []interface{}
+	if err := json.Unmarshal(state, &history); err != nil {
+		return fmt.Errorf("local conversation %q: %w", conversationID, err)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.histories[conversationID] = history
+	return nil
+}
+
+// CreateResponse sends the full history plus the new input to the backend
+func (h *HistoryAdapter) CreateResponse(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
+	id, input, full, err := h.expand(payload)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.Backend.CreateResponse(ctx, full)
+	if err != nil {
+		return nil, err
+	}
+	h.commit(id, input, resp)
+	return resp, nil
+}
+
+// StreamResponse is CreateResponse with streamed output text
+func (h *HistoryAdapter) StreamResponse(ctx context.Context, payload map[string]interface{}, onText func(string)) (map[string]interface{}, error) {
+	id, input, full, err := h.expand(payload)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.Backend.StreamResponse(ctx, full, onText)
+	if err != nil {
+		return nil, err
+	}
+	h.commit(id, input, resp)
+	return resp, nil
+}
+
+// expand replaces the conversation reference with the stored history
+func (h *HistoryAdapter) expand(payload map[string]interface{}) (string, []interface{}, map[string]interface{}, error) {
+	id, _ := payload["conversation"].(string)
+
+	h.mu.Lock()
+	history, ok := h.histories[id]
+	h.mu.Unlock()
+	if !ok {
+		return "", nil, nil, fmt.Errorf("%w: local conversation %q", ErrConversationNotFound, id)
+	}
+
+	input := inputItems(payload["input"])
+
+	full := make(map[string]interface{}, len(payload))
+	for k, v := range payload {
+		if k != "conversation" {
+			full[k] = v
+		}
+	}
+	items := make([]interface{}, 0, len(history)+len(input))
+	items = append(items, history...)
+	items = append(items, input...)
+	full["input"] = items
+	if h.NoStore {
+		full["store"] = false
+		full["include"] = []string{"reasoning.encrypted_content"}
+	}
+
+	return id, input, full, nil
+}
+
+// commit appends the turn's input and the model's output to the history
+func (h *HistoryAdapter) commit(id string, input []interface{}, resp map[string]interface{}) {
+	output, _ := resp["output"].([]interface{})
+	if h.NoStore {
+		output = withoutIDs(output)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	history := h.histories[id]
+	history = append(history, input...)
+	history = append(history, output...)
+	h.histories[id] = history
+}
+
+// withoutIDs returns copies of items without their server-assigned "id"
+func withoutIDs(items []interface{}) []interface{} {
+	out := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			out = append(out, item)
+			continue
+		}
+		c := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			if k != "id" {
+				c[k] = v
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// Prefixes of conversation IDs minted client-side by HistoryAdapter and
+// ChainAdapter; no server knows these IDs
+const (
+	localIDPrefix = "local_"
+	chainIDPrefix = "chain_"
+)
+
+// IsClientSideID reports whether id names a conversation kept client-side
+// rather than one stored by the server
+func IsClientSideID(id string) bool {
+	return strings.HasPrefix(id, localIDPrefix) || IsChainID(id)
+}
+
+// localID returns a random client-side conversation ID with prefix
+func localID(prefix string) (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(buf), nil
+}
+
+// inputItems normalizes the loop's input (a string or a list of items)
+func inputItems(v interface{}) []interface{} {
+	switch in := v.(type) {
+	case []interface{}:
+		return in
+	case []map[string]interface{}:
+		items := make([]interface{}, 0, len(in))
+		for _, item := range in {
+			items = append(items, item)
+		}
+		return items
+	case string:
+		return []interface{}{map[string]interface{}{"role": "user", "content": in}}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// recordingBackend answers every call with a fixed message and keeps the payloads
+type recordingBackend struct {
+	payloads []map[string]interface{}
+}
+
+func (b *recordingBackend) CreateConversation(ctx context.Context, systemPrompt string) (string, error) {
+	panic("adapter must not create server-side conversations")
+}
+
+func (b *recordingBackend) CreateResponse(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
+	b.payloads = append(b.payloads, payload)
+	return map[string]interface{}{
+		"id": fmt.Sprintf("resp_%d", len(b.payloads)),
+		"output": []interface{}{
+			map[string]interface{}{"type": "message", "id": "msg_1", "role": "assistant", "content": []interface{}{
+				map[string]interface{}{"type": "output_text", "text": "ok"},
+			}},
+		},
+	}, nil
+}
+
+func (b *recordingBackend) StreamResponse(ctx context.Context, payload map[string]interface{}, onText func(string)) (map[string]interface{}, error) {
+	return b.CreateResponse(ctx, payload)
+}
+
+func TestHistoryAdapterReplaysConversation(t *testing.T) {
+	ctx := context.Background()
+	backend := &recordingBackend{}
+	adapter := NewHistoryAdapter(backend)
+
+	id, err := adapter.CreateConversation(ctx, "system prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CanResume(adapter, id) || CanResume(adapter, "conv_other") {
+		t.Fatal("CanResume should only accept conversations held by the adapter")
+	}
+
+	for _, text := range []string{"first", "second"} {
+		_, err := adapter.CreateResponse(ctx, map[string]interface{}{
+			"model":        "m",
+			"conversation": id,
+			"input":        []map[string]interface{}{{"role": "user", "content": text}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	last := backend.payloads[1]
+	if _, ok := last["conversation"]; ok {
+		t.Error("conversation must not be forwarded to the backend")
+	}
+	// developer prompt + first input + first output + second input
+	if input, _ := last["input"].([]interface{}); len(input) != 4 {
+		t.Errorf("second call input has %d items, want 4: %v", len(input), input)
+	}
+
+	if _, err := adapter.CreateResponse(ctx, map[string]interface{}{"conversation": "missing"}); err == nil {
+		t.Error("expected error for unknown conversation")
+	}
+}
+
+func TestHistoryAdapterNoStoreAndState(t *testing.T) {
+	ctx := context.Background()
+	backend := &recordingBackend{}
+	adapter := NewHistoryAdapter(backend)
+	adapter.NoStore = true
+
+	id, _ := adapter.CreateConversation(ctx, "system prompt")
+	adapter.CreateResponse(ctx, map[string]interface{}{"conversation": id, "input": "first"})
+	if p := backend.payloads[0]; p["store"] != false || fmt.Sprint(p["include"]) != "[reasoning.encrypted_content]" {
+		t.Errorf("payload = %v", p)
+	}
+
+	state, err := SaveState(adapter, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewHistoryAdapter(backend)
+	if err := RestoreState(restored, id, state); err != nil {
+		t.Fatal(err)
+	}
+	restored.CreateResponse(ctx, map[string]interface{}{"conversation": id, "input": "second"})
+	input := backend.payloads[1]["input"].([]interface{})
+	if len(input) != 4 {
+		t.Fatalf("restored history has %d items, want 4: %v", len(input), input)
+	}
+	if _, ok := input[2].(map[string]interface{})["id"]; ok {
+		t.Error("output item IDs must be dropped when store=false")
+	}
+}
+
+func TestIsClientSideID(t *testing.T) {
+	ctx := context.Background()
+	local, _ := NewHistoryAdapter(&recordingBackend{}).CreateConversation(ctx, "system")
+	chain, _ := NewChainAdapter(&recordingBackend{}).CreateConversation(ctx, "system")
+	for id, want := range map[string]bool{local: true, chain: true, "conv_123": false, "": false} {
+		if got := IsClientSideID(id); got != want {
+			t.Errorf("IsClientSideID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Price is the USD cost per million tokens for one model
+type Price struct {
+	Input       float64 `json:"input"`
+	CachedInput float64 `json:"cached_input"` // 0 = billed like Input
+	Output      float64 `json:"output"`       // Reasoning tokens are billed as output
+}
+
+// PriceTable maps model names (or name prefixes) to prices
+type PriceTable map[string]Price
+
+// DefaultPrices returns list prices for common models. They are estimates
+// that go stale; override them with ParsePriceTable.
+func DefaultPrices() PriceTable {
+	return PriceTable{
+		"gpt-5":         {Input: 1.25, CachedInput: 0.125, Output: 10},
+		"gpt-5-codex":   {Input: 1.25, CachedInput: 0.125, Output: 10},
+		"gpt-5-mini":    {Input: 0.25, CachedInput: 0.025, Output: 2},
+		"gpt-5-nano":    {Input: 0.05, CachedInput: 0.005, Output: 0.4},
+		"gpt-5.1":       {Input: 1.25, CachedInput: 0.125, Output: 10},
+		"gpt-5.1-codex": {Input: 1.25, CachedInput: 0.125, Output: 10},
+		"gpt-5.2":       {Input: 1.75, CachedInput: 0.175, Output: 14},
+		"gpt-5.2-codex": {Input: 1.75, CachedInput: 0.175, Output: 14},
+	}
+}
+
+// Lookup finds the price for model by exact name, then by the longest
+// matching prefix (so dated snapshots like gpt-5-2025-08-07 resolve)
+func (t PriceTable) Lookup(model string) (Price, bool) {
+	if p, ok := t[model]; ok {
+		return p, true
+	}
+	best := ""
+	for name := range t {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return t[best], true
+}
+
+// Cost estimates the USD cost of u
+func (p Price) Cost(u Usage) float64 {
+	cached := p.CachedInput
+	if cached == 0 {
+		cached = p.Input
+	}
+	uncached := u.InputTokens - u.CachedTokens
+	return (float64(uncached)*p.Input + float64(u.CachedTokens)*cached + float64(u.OutputTokens)*p.Output) / 1e6
+}
+
+// ParsePriceTable decodes a JSON object of model -> price and layers it
+// over DefaultPrices:
+//
+//	{"gpt-5.2-codex": {"input": 1.75, "cached_input": 0.175, "output": 14}}
+func ParsePriceTable(data []byte) (PriceTable, error) {
+	var overrides PriceTable
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("invalid price table: %w", err)
+	}
+	table := DefaultPrices()
+	for model, p := range overrides {
+		if p.Input < 0 || p.CachedInput < 0 || p.Output < 0 {
+			return nil, fmt.Errorf("invalid price table: negative price for %s", model)
+		}
+		table[model] = p
+	}
+	return table, nil
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Provider is a Responses API backend the agent loop can drive
+type Provider interface {
+	CreateConversation(ctx context.Context, systemPrompt string) (string, error)
+	CreateResponse(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error)
+	StreamResponse(ctx context.Context, payload map[string]interface{}, onText func(string)) (map[string]interface{}, error)
+}
+
+// Resumer is implemented by providers that know whether a saved
+// conversation ID can still be continued
+type Resumer interface {
+	CanResume(conversationID string) bool
+}
+
+// CanResume reports whether p can continue conversationID; providers
+// without a Resumer implementation are assumed to keep conversations
+func CanResume(p Provider, conversationID string) bool {
+	if r, ok := p.(Resumer); ok {
+		return r.CanResume(conversationID)
+	}
+	return true
+}
+
+// Deleter is implemented by providers that store conversations server-side
+// and can delete them
+type Deleter interface {
+	DeleteConversation(ctx context.Context, conversationID string) error
+}
+
+// Appender is implemented by providers that can add existing items (such as
+// a replayed transcript) to a conversation
+type Appender interface {
+	AddItems(ctx context.Context, conversationID string, items []interface{}) error
+}
+
+// StateKeeper is implemented by providers that keep conversations
+// client-side. The state is opaque JSON the caller persists (in the session
+// file) so a later process can continue the conversation.
+type StateKeeper interface {
+	State(conversationID string) (json.RawMessage, error)
+	Restore(conversationID string, state json.RawMessage) error
+}
+
+// SaveState returns the client-side state of conversationID, or nil for
+// providers that keep conversations server-side
+func SaveState(p Provider, conversationID string) (json.RawMessage, error) {
+	if k, ok := p.(StateKeeper); ok && conversationID != "" {
+		return k.State(conversationID)
+	}
+	return nil, nil
+}
+
+// RestoreState loads state saved by SaveState; empty state and providers
+// that keep conversations server-side are a no-op
+func RestoreState(p Provider, conversationID string, state json.RawMessage) error {
+	if k, ok := p.(StateKeeper); ok && conversationID != "" && len(state) > 0 {
+		return k.Restore(conversationID, state)
+	}
+	return nil
+}
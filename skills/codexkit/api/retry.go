@@ -0,0 +1,270 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// HTTPError is a non-200 response from the API
+type HTTPError struct {
+	StatusCode int
+	Body       string
+	Header     http.Header
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// ErrConversationNotFound is returned by providers that keep history
+// client-side when asked for a conversation they do not hold
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// IsConversationNotFound reports whether err means the conversation or
+// previous response a request referred to no longer exists (expired or
+// deleted server-side), as opposed to e.g. an unknown model or a 404 from
+// a proxy in front of the API
+func IsConversationNotFound(err error) bool {
+	if errors.Is(err, ErrConversationNotFound) {
+		return true
+	}
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		return false
+	}
+	var body struct {
+		Error struct {
+			Param string `json:"param"`
+			Code  string `json:"code"`
+		} `json:"error"`
+	}
+	if json.Unmarshal([]byte(httpErr.Body), &body) != nil {
+		return false
+	}
+	switch body.Error.Param {
+	case "conversation", "previous_response_id":
+		return true
+	}
+	switch body.Error.Code {
+	case "conversation_not_found", "previous_response_not_found":
+		return true
+	}
+	return false
+}
+
+// IsContextLengthExceeded reports whether err means the request did not
+// fit the model's context window
+func IsContextLengthExceeded(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "context_length_exceeded") ||
+		strings.Contains(msg, "context window") ||
+		strings.Contains(msg, "maximum context length")
+}
+
+// RetryPolicy controls how transient API failures are retried
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first (<=1 disables retries)
+	MaxWait     time.Duration // Cap on cumulative time spent sleeping between attempts
+	BaseDelay   time.Duration // First backoff step, doubled per attempt
+	MaxDelay    time.Duration // Cap on a single computed backoff step
+	Log         io.Writer     // Where retries are reported (nil = silent)
+}
+
+// DefaultRetryPolicy returns the policy used when none is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		MaxWait:     5 * time.Minute,
+		BaseDelay:   time.Second,
+		MaxDelay:    60 * time.Second,
+	}
+}
+
+// retryClass is how safe it is to send a failed request again
+type retryClass int
+
+const (
+	noRetry        retryClass = iota
+	retryUnsent               // The request provably never reached the server
+	retryAmbiguous            // The request may have been applied before it failed
+)
+
+// retry runs fn until it succeeds, fails permanently, or the policy is
+// exhausted. A failure that may have reached the server is resent only
+// after verify confirms the request was not applied; a nil verify means
+// fn is safe to repeat.
+func (p RetryPolicy) retry(ctx context.Context, op string, fn func() error, verify func() error) error {
+	var waited time.Duration
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		reason, class := classifyRetry(ctx, err)
+		if class == noRetry || attempt >= p.MaxAttempts {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			rateLimited := httpErr.StatusCode == http.StatusTooManyRequests
+			if hint, ok := retryAfterHint(httpErr.Header, rateLimited, time.Now()); ok && hint > 0 {
+				delay = hint
+			}
+		}
+		if waited+delay > p.MaxWait {
+			return fmt.Errorf("%w (gave up after %d attempts, retry budget %s exhausted)", err, attempt, p.MaxWait)
+		}
+
+		if p.Log != nil {
+			fmt.Fprintf(p.Log, "[RETRY] %s attempt %d/%d failed (%s), retrying in %s\n",
+				op, attempt, p.MaxAttempts, reason, delay.Round(100*time.Millisecond))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		waited += delay
+
+		// Checked after the delay so a request still being processed
+		// has had time to land
+		if class == retryAmbiguous && verify != nil {
+			if verr := verify(); verr != nil {
+				return fmt.Errorf("%w (not retried: %v)", err, verr)
+			}
+		}
+	}
+}
+
+// backoff returns a jittered exponential delay for the given attempt (1-based)
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = time.Second
+	}
+	d := base << (attempt - 1)
+	if p.MaxDelay > 0 && (d > p.MaxDelay || d <= 0) {
+		d = p.MaxDelay
+	}
+	// Equal jitter: half fixed, half random
+	half := d / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+// classifyRetry reports whether err is transient and whether the request
+// may have reached the server, with a short reason for logs
+func classifyRetry(ctx context.Context, err error) (string, retryClass) {
+	if ctx.Err() != nil {
+		return "", noRetry // Caller cancelled: never retry
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch code := httpErr.StatusCode; {
+		case code == http.StatusTooManyRequests:
+			// Quota exhaustion is reported as 429 but will not clear by waiting
+			if strings.Contains(httpErr.Body, "insufficient_quota") {
+				return "", noRetry
+			}
+			return "HTTP 429 rate limited", retryUnsent
+		case code == http.StatusServiceUnavailable && (httpErr.Header.Get("Retry-After") != "" || httpErr.Header.Get("Retry-After-Ms") != ""):
+			// Shed load: the server turned the request away unprocessed
+			return "HTTP 503 unavailable", retryUnsent
+		case code == http.StatusRequestTimeout, code == http.StatusTooEarly:
+			return fmt.Sprintf("HTTP %d", code), retryAmbiguous
+		case code >= 500:
+			return fmt.Sprintf("HTTP %d server error", code), retryAmbiguous
+		}
+		return "", noRetry
+	}
+
+	// Resolving and connecting fail before any of the request is sent
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTemporary || dnsErr.IsTimeout {
+			return "DNS error", retryUnsent
+		}
+		return "", noRetry
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" || errors.Is(err, syscall.ECONNREFUSED) {
+		return "connection failed", retryUnsent
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "network timeout", retryAmbiguous
+	}
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return "connection closed", retryAmbiguous
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.EPIPE):
+		return "connection error", retryAmbiguous
+	}
+	if opErr != nil {
+		return "network error", retryAmbiguous
+	}
+	return "", noRetry
+}
+
+// retryAfterHint reads the server's requested delay from Retry-After or
+// retry-after-ms, and for rate limits from the x-ratelimit-reset-* headers
+func retryAfterHint(h http.Header, rateLimited bool, now time.Time) (time.Duration, bool) {
+	if h == nil {
+		return 0, false
+	}
+
+	if ms := h.Get("Retry-After-Ms"); ms != "" {
+		if v, err := strconv.ParseFloat(ms, 64); err == nil && v >= 0 {
+			return time.Duration(v * float64(time.Millisecond)), true
+		}
+	}
+
+	if ra := h.Get("Retry-After"); ra != "" {
+		if secs, err := strconv.ParseFloat(ra, 64); err == nil && secs >= 0 {
+			return time.Duration(secs * float64(time.Second)), true
+		}
+		if t, err := http.ParseTime(ra); err == nil {
+			if d := t.Sub(now); d > 0 {
+				return d, true
+			}
+			return 0, true
+		}
+	}
+
+	if !rateLimited {
+		return 0, false
+	}
+
+	// OpenAI reports resets as durations like "1s", "6m0s" or "20ms"
+	var longest time.Duration
+	found := false
+	for _, key := range []string{"X-Ratelimit-Reset-Requests", "X-Ratelimit-Reset-Tokens"} {
+		if v := h.Get(key); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+				found = true
+				if d > longest {
+					longest = d
+				}
+			}
+		}
+	}
+	return longest, found
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassifyRetry(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		err  error
+		want retryClass
+	}{
+		{&HTTPError{StatusCode: 429, Body: `{"error":{"code":"rate_limit_exceeded"}}`}, retryUnsent},
+		{&HTTPError{StatusCode: 429, Body: `{"error":{"code":"insufficient_quota"}}`}, noRetry},
+		{&HTTPError{StatusCode: 503, Header: http.Header{"Retry-After": {"2"}}}, retryUnsent},
+		{&HTTPError{StatusCode: 503}, retryAmbiguous},
+		{&HTTPError{StatusCode: 500}, retryAmbiguous},
+		{&HTTPError{StatusCode: 409}, noRetry},
+		{&HTTPError{StatusCode: 400}, noRetry},
+		{&HTTPError{StatusCode: 401}, noRetry},
+		{&HTTPError{StatusCode: 404}, noRetry},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, retryUnsent},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}, noRetry},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}}, retryUnsent},
+		{&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, retryAmbiguous},
+		{fmt.Errorf("write: %w", syscall.EPIPE), retryAmbiguous},
+		{io.ErrUnexpectedEOF, retryAmbiguous},
+		{errors.New("invalid character"), noRetry},
+	}
+	for _, c := range cases {
+		if _, got := classifyRetry(ctx, c.err); got != c.want {
+			t.Errorf("classifyRetry(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, class := classifyRetry(cancelled, &HTTPError{StatusCode: 429}); class != noRetry {
+		t.Error("cancelled context must not be retried")
+	}
+}
+
+func TestIsConversationNotFound(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("API error: %w", &HTTPError{StatusCode: 404, Body: `{"error":{"message":"Conversation with id 'conv_1' not found.","param":"conversation"}}`}), true},
+		{&HTTPError{StatusCode: 404, Body: `{"error":{"message":"Conversation not found","code":"conversation_not_found"}}`}, true},
+		{&HTTPError{StatusCode: 404, Body: `<html><body>404: no route for /v1/responses (conversation gateway)</body></html>`}, false},
+		{&HTTPError{StatusCode: 404, Body: `{"error":{"message":"Previous response with id 'resp_1' not found.","param":"previous_response_id"}}`}, true},
+		{&HTTPError{StatusCode: 404, Body: `{"error":{"message":"The model 'gpt-x' does not exist","code":"model_not_found"}}`}, false},
+		{&HTTPError{StatusCode: 400, Body: `{"error":{"message":"Conversation is invalid"}}`}, false},
+		{fmt.Errorf("%w: local conversation %q", ErrConversationNotFound, "local_1"), true},
+		{errors.New("conversation not found"), false},
+	}
+	for _, c := range cases {
+		if got := IsConversationNotFound(c.err); got != c.want {
+			t.Errorf("IsConversationNotFound(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestIsContextLengthExceeded(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&HTTPError{StatusCode: 400, Body: `{"error":{"message":"Your input exceeds the context window of this model.","code":"context_length_exceeded"}}`}, true},
+		{errors.New("response failed: This model's maximum context length is 128000 tokens"), true},
+		{&HTTPError{StatusCode: 400, Body: `{"error":{"message":"Invalid value for 'reasoning.effort'"}}`}, false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		if got := IsContextLengthExceeded(c.err); got != c.want {
+			t.Errorf("IsContextLengthExceeded(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestRetryAfterHint(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		header      http.Header
+		rateLimited bool
+		want        time.Duration
+		ok          bool
+	}{
+		{http.Header{"Retry-After": {"3"}}, false, 3 * time.Second, true},
+		{http.Header{"Retry-After-Ms": {"250"}}, false, 250 * time.Millisecond, true},
+		{http.Header{"Retry-After": {now.Add(10 * time.Second).Format(http.TimeFormat)}}, false, 10 * time.Second, true},
+		{http.Header{"X-Ratelimit-Reset-Requests": {"1s"}, "X-Ratelimit-Reset-Tokens": {"6m0s"}}, true, 6 * time.Minute, true},
+		{http.Header{"X-Ratelimit-Reset-Tokens": {"6m0s"}}, false, 0, false},
+		{http.Header{}, true, 0, false},
+	}
+	for _, c := range cases {
+		got, ok := retryAfterHint(c.header, c.rateLimited, now)
+		if got != c.want || ok != c.ok {
+			t.Errorf("retryAfterHint(%v) = %v, %v; want %v, %v", c.header, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestRetryStopsAtMaxAttempts(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, MaxWait: time.Second, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	calls := 0
+	err := policy.retry(context.Background(), "test", func() error {
+		calls++
+		return &HTTPError{StatusCode: 502}
+	}, nil)
+	if err == nil || calls != 3 {
+		t.Fatalf("calls = %d, err = %v; want 3 attempts and an error", calls, err)
+	}
+
+	calls = 0
+	err = policy.retry(context.Background(), "test", func() error {
+		calls++
+		if calls < 2 {
+			return &HTTPError{StatusCode: 429}
+		}
+		return nil
+	}, nil)
+	if err != nil || calls != 2 {
+		t.Fatalf("calls = %d, err = %v; want success on second attempt", calls, err)
+	}
+}
+
+func TestRetryVerifiesAmbiguousFailures(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, MaxWait: time.Second, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	applied := errors.New("already applied")
+	calls, checks := 0, 0
+	err := policy.retry(context.Background(), "test", func() error {
+		calls++
+		return io.ErrUnexpectedEOF
+	}, func() error {
+		checks++
+		return applied
+	})
+	if calls != 1 || checks != 1 || !errors.Is(err, io.ErrUnexpectedEOF) || !strings.Contains(err.Error(), "already applied") {
+		t.Errorf("calls = %d, checks = %d, err = %v; want one attempt stopped by the check", calls, checks, err)
+	}
+
+	// Failures before the request was sent need no check
+	calls, checks = 0, 0
+	err = policy.retry(context.Background(), "test", func() error {
+		calls++
+		if calls < 2 {
+			return &HTTPError{StatusCode: 429}
+		}
+		return nil
+	}, func() error {
+		checks++
+		return applied
+	})
+	if err != nil || calls != 2 || checks != 0 {
+		t.Errorf("calls = %d, checks = %d, err = %v; want an unchecked retry", calls, checks, err)
+	}
+}
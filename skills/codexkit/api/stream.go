@@ -0,0 +1,266 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamResponse makes a streaming (SSE) request to the Responses API.
+// onText is called with each output_text delta as it arrives; the returned
+// response has the same shape as CreateResponse so ExtractCallsAndText works
+// unchanged. Servers that ignore stream:true and answer with plain JSON are
+// handled as a buffered response.
+func (c *Client) StreamResponse(ctx context.Context, payload map[string]interface{}, onText func(string)) (map[string]interface{}, error) {
+	streamPayload := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		streamPayload[k] = v
+	}
+	streamPayload["stream"] = true
+
+	// Retries only cover establishing the stream; once deltas have been
+	// printed a retry would duplicate output
+	resp, err := c.send(ctx, "POST", "/responses", streamPayload, "text/event-stream")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Fallback: server answered without streaming
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		var result map[string]interface{}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return nil, err
+		}
+		if _, text := ExtractCallsAndText(result); text != "" && onText != nil {
+			onText(text)
+		}
+		return result, nil
+	}
+
+	asm := newStreamAssembler(onText)
+	if err := readSSE(resp.Body, asm.handle); err != nil {
+		return nil, err
+	}
+	return asm.result()
+}
+
+// readSSE parses a text/event-stream body and calls fn once per event
+func readSSE(r io.Reader, fn func(event, data string) error) error {
+	reader := bufio.NewReader(r)
+	var event string
+	var dataLines []string
+
+	dispatch := func() error {
+		if len(dataLines) == 0 {
+			event = ""
+			return nil
+		}
+		data := strings.Join(dataLines, "\n")
+		ev := event
+		event, dataLines = "", nil
+		if data == "[DONE]" {
+			return nil
+		}
+		return fn(ev, data)
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "":
+			if derr := dispatch(); derr != nil {
+				return derr
+			}
+		case strings.HasPrefix(line, ":"):
+			// Comment / keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			dataLines = append(dataLines, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+
+		if err == io.EOF {
+			return dispatch()
+		}
+	}
+}
+
+// streamAssembler rebuilds a Responses API response object from SSE events
+type streamAssembler struct {
+	onText    func(string)
+	items     map[int]map[string]interface{}
+	maxIndex  int
+	final     map[string]interface{}
+	failure   error
+	completed bool
+}
+
+func newStreamAssembler(onText func(string)) *streamAssembler {
+	return &streamAssembler{onText: onText, items: map[int]map[string]interface{}{}, maxIndex: -1}
+}
+
+// handle applies one SSE event to the assembled response
+func (a *streamAssembler) handle(event, data string) error {
+	var ev map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &ev); err != nil {
+		return fmt.Errorf("invalid stream event: %w", err)
+	}
+
+	evType, _ := ev["type"].(string)
+	if evType == "" {
+		evType = event
+	}
+	index := -1
+	if f, ok := ev["output_index"].(float64); ok {
+		index = int(f)
+	}
+
+	switch evType {
+	case "response.output_item.added", "response.output_item.done":
+		if item, ok := ev["item"].(map[string]interface{}); ok && index >= 0 {
+			a.setItem(index, item)
+		}
+
+	case "response.content_part.added":
+		item := a.item(index)
+		part, ok := ev["part"].(map[string]interface{})
+		if item == nil || !ok {
+			return nil
+		}
+		content, _ := item["content"].([]interface{})
+		item["content"] = append(content, part)
+
+	case "response.output_text.delta":
+		delta, _ := ev["delta"].(string)
+		if delta == "" {
+			return nil
+		}
+		if a.onText != nil {
+			a.onText(delta)
+		}
+		if part := a.textPart(index, ev); part != nil {
+			text, _ := part["text"].(string)
+			part["text"] = text + delta
+		}
+
+	case "response.function_call_arguments.delta":
+		delta, _ := ev["delta"].(string)
+		if item := a.item(index); item != nil {
+			args, _ := item["arguments"].(string)
+			item["arguments"] = args + delta
+		}
+
+	case "response.function_call_arguments.done":
+		if item := a.item(index); item != nil {
+			if args, ok := ev["arguments"].(string); ok {
+				item["arguments"] = args
+			}
+		}
+
+	case "response.completed", "response.incomplete":
+		a.completed = true
+		if resp, ok := ev["response"].(map[string]interface{}); ok {
+			a.final = resp
+		}
+
+	case "response.failed":
+		a.failure = fmt.Errorf("response failed: %s", describeError(ev["response"]))
+
+	case "error":
+		a.failure = fmt.Errorf("stream error: %s", describeError(ev))
+	}
+
+	return nil
+}
+
+// result returns the assembled response, preferring the server's final copy
+func (a *streamAssembler) result() (map[string]interface{}, error) {
+	if a.failure != nil {
+		return nil, a.failure
+	}
+	if !a.completed {
+		return nil, fmt.Errorf("stream ended before response completed")
+	}
+
+	output := make([]interface{}, 0, len(a.items))
+	for i := 0; i <= a.maxIndex; i++ {
+		if item, ok := a.items[i]; ok {
+			output = append(output, item)
+		}
+	}
+
+	result := a.final
+	if result == nil {
+		result = map[string]interface{}{}
+	}
+	if final, ok := result["output"].([]interface{}); !ok || len(final) == 0 {
+		result["output"] = output
+	}
+	return result, nil
+}
+
+func (a *streamAssembler) setItem(index int, item map[string]interface{}) {
+	a.items[index] = item
+	if index > a.maxIndex {
+		a.maxIndex = index
+	}
+}
+
+func (a *streamAssembler) item(index int) map[string]interface{} {
+	if index < 0 {
+		return nil
+	}
+	return a.items[index]
+}
+
+// textPart returns the content part an output_text delta belongs to,
+// creating it if the server skipped content_part.added
+func (a *streamAssembler) textPart(index int, ev map[string]interface{}) map[string]interface{} {
+	item := a.item(index)
+	if item == nil {
+		return nil
+	}
+	contentIndex := 0
+	if f, ok := ev["content_index"].(float64); ok {
+		contentIndex = int(f)
+	}
+	content, _ := item["content"].([]interface{})
+	for len(content) <= contentIndex {
+		content = append(content, map[string]interface{}{"type": "output_text", "text": ""})
+	}
+	item["content"] = content
+	part, _ := content[contentIndex].(map[string]interface{})
+	return part
+}
+
+// describeError extracts a readable message from an error-bearing event
+func describeError(v interface{}) string {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return "unknown error"
+	}
+	if inner, ok := m["error"].(map[string]interface{}); ok {
+		m = inner
+	}
+	if msg, ok := m["message"].(string); ok && msg != "" {
+		if code, ok := m["code"].(string); ok && code != "" {
+			return fmt.Sprintf("%s (%s)", msg, code)
+		}
+		return msg
+	}
+	data, _ := json.Marshal(m)
+	return string(data)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleStream = `event: response.created
+data: {"type":"response.created","response":{"id":"resp_1","output":[]}}
+
+: keep-alive
+
+event: response.output_item.added
+data: {"type":"response.output_item.added","output_index":0,"item":{"type":"message","id":"msg_1","content":[]}}
+
+event: response.content_part.added
+data: {"type":"response.content_part.added","output_index":0,"content_index":0,"part":{"type":"output_text","text":""}}
+
+event: response.output_text.delta
+data: {"type":"response.output_text.delta","output_index":0,"content_index":0,"delta":"[PROGRESS] "}
+
+event: response.output_text.delta
+data: {"type":"response.output_text.delta","output_index":0,"content_index":0,"delta":"Reading files\n"}
+
+event: response.output_item.added
+data: {"type":"response.output_item.added","output_index":1,"item":{"type":"function_call","call_id":"call_1","name":"Read","arguments":""}}
+
+event: response.function_call_arguments.delta
+data: {"type":"response.function_call_arguments.delta","output_index":1,"delta":"{\"path\":"}
+
+event: response.function_call_arguments.delta
+data: {"type":"response.function_call_arguments.delta","output_index":1,"delta":"\"main.go\"}"}
+
+event: response.completed
+data: {"type":"response.completed","response":{"id":"resp_1","status":"completed"}}
+
+data: [DONE]
+
+`
+
+func TestStreamAssemblesTextAndCalls(t *testing.T) {
+	var deltas []string
+	asm := newStreamAssembler(func(s string) { deltas = append(deltas, s) })
+	if err := readSSE(strings.NewReader(sampleStream), asm.handle); err != nil {
+		t.Fatalf("readSSE: %v", err)
+	}
+
+	resp, err := asm.result()
+	if err != nil {
+		t.Fatalf("result: %v", err)
+	}
+
+	if got := strings.Join(deltas, ""); got != "[PROGRESS] Reading files\n" {
+		t.Errorf("streamed text = %q", got)
+	}
+
+	calls, text := ExtractCallsAndText(resp)
+	if text != "[PROGRESS] Reading files\n" {
+		t.Errorf("assembled text = %q", text)
+	}
+	if len(calls) != 1 || calls[0].Name != "Read" || calls[0].CallID != "call_1" || calls[0].Arguments != `{"path":"main.go"}` {
+		t.Errorf("assembled calls = %+v", calls)
+	}
+}
+
+func TestStreamFailureEvent(t *testing.T) {
+	stream := "event: response.failed\ndata: {\"type\":\"response.failed\",\"response\":{\"error\":{\"message\":\"boom\",\"code\":\"server_error\"}}}\n\n"
+	asm := newStreamAssembler(nil)
+	if err := readSSE(strings.NewReader(stream), asm.handle); err != nil {
+		t.Fatalf("readSSE: %v", err)
+	}
+	if _, err := asm.result(); err == nil || !strings.Contains(err.Error(), "boom (server_error)") {
+		t.Fatalf("expected failure, got %v", err)
+	}
+}
+
+func TestStreamTruncated(t *testing.T) {
+	asm := newStreamAssembler(nil)
+	stream := "data: {\"type\":\"response.output_text.delta\",\"output_index\":0,\"delta\":\"x\"}"
+	if err := readSSE(strings.NewReader(stream), asm.handle); err != nil {
+		t.Fatalf("readSSE: %v", err)
+	}
+	if _, err := asm.result(); err == nil {
+		t.Fatal("expected error for stream without response.completed")
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+// Usage counts the tokens billed for one or more responses
+type Usage struct {
+	Requests        int   `json:"requests"`
+	InputTokens     int64 `json:"input_tokens"`
+	CachedTokens    int64 `json:"cached_tokens"` // Part of InputTokens served from the prompt cache
+	OutputTokens    int64 `json:"output_tokens"`
+	ReasoningTokens int64 `json:"reasoning_tokens"` // Part of OutputTokens spent on reasoning
+}
+
+// Add accumulates o into u
+func (u *Usage) Add(o Usage) {
+	u.Requests += o.Requests
+	u.InputTokens += o.InputTokens
+	u.CachedTokens += o.CachedTokens
+	u.OutputTokens += o.OutputTokens
+	u.ReasoningTokens += o.ReasoningTokens
+}
+
+// Total returns input plus output tokens
+func (u Usage) Total() int64 {
+	return u.InputTokens + u.OutputTokens
+}
+
+// ExtractUsage reads the usage block of a response. Chat Completions style
+// field names are accepted for compatible servers; ok is false when the
+// server reported nothing.
+func ExtractUsage(resp map[string]interface{}) (Usage, bool) {
+	raw, ok := resp["usage"].(map[string]interface{})
+	if !ok {
+		return Usage{}, false
+	}
+
+	u := Usage{Requests: 1}
+	u.InputTokens = firstCount(raw, "input_tokens", "prompt_tokens")
+	u.OutputTokens = firstCount(raw, "output_tokens", "completion_tokens")
+	if details, ok := firstMap(raw, "input_tokens_details", "prompt_tokens_details"); ok {
+		u.CachedTokens = firstCount(details, "cached_tokens")
+	}
+	if details, ok := firstMap(raw, "output_tokens_details", "completion_tokens_details"); ok {
+		u.ReasoningTokens = firstCount(details, "reasoning_tokens")
+	}
+	return u, true
+}
+
+func firstCount(m map[string]interface{}, keys ...string) int64 {
+	for _, key := range keys {
+		if f, ok := m[key].(float64); ok {
+			return int64(f)
+		}
+	}
+	return 0
+}
+
+func firstMap(m map[string]interface{}, keys ...string) (map[string]interface{}, bool) {
+	for _, key := range keys {
+		if v, ok := m[key].(map[string]interface{}); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestExtractUsage(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want Usage
+		ok   bool
+	}{
+		{"responses", `{"usage":{"input_tokens":1200,"input_tokens_details":{"cached_tokens":1000},"output_tokens":300,"output_tokens_details":{"reasoning_tokens":200},"total_tokens":1500}}`,
+			Usage{Requests: 1, InputTokens: 1200, CachedTokens: 1000, OutputTokens: 300, ReasoningTokens: 200}, true},
+		{"chat completions names", `{"usage":{"prompt_tokens":10,"prompt_tokens_details":{"cached_tokens":4},"completion_tokens":5}}`,
+			Usage{Requests: 1, InputTokens: 10, CachedTokens: 4, OutputTokens: 5}, true},
+		{"missing", `{"output":[]}`, Usage{}, false},
+	}
+	for _, tc := range cases {
+		var resp map[string]interface{}
+		if err := json.Unmarshal([]byte(tc.body), &resp); err != nil {
+			t.Fatal(err)
+		}
+		got, ok := ExtractUsage(resp)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%s: got %+v, %v; want %+v, %v", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestPriceLookupAndCost(t *testing.T) {
+	table := PriceTable{
+		"gpt-5":      {Input: 1, CachedInput: 0.1, Output: 10},
+		"gpt-5-mini": {Input: 0.5, Output: 2},
+	}
+
+	if p, ok := table.Lookup("gpt-5-mini-2025-08-07"); !ok || p.Input != 0.5 {
+		t.Errorf("snapshot should resolve to the longest prefix, got %+v, %v", p, ok)
+	}
+	if _, ok := table.Lookup("gpt-50"); ok {
+		t.Error("prefix match must stop at a dash boundary")
+	}
+
+	u := Usage{InputTokens: 2_000_000, CachedTokens: 1_000_000, OutputTokens: 500_000}
+	if got := table["gpt-5"].Cost(u); math.Abs(got-6.1) > 1e-9 {
+		t.Errorf("cost = %v, want 6.1", got)
+	}
+	// No cached price: cached tokens billed at the input rate
+	if got := table["gpt-5-mini"].Cost(u); math.Abs(got-2) > 1e-9 {
+		t.Errorf("cost = %v, want 2", got)
+	}
+}
+
+func TestParsePriceTable(t *testing.T) {
+	table, err := ParsePriceTable([]byte(`{"my-model":{"input":2,"output":8},"gpt-5":{"input":9,"output":9}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table["my-model"].Output != 8 || table["gpt-5"].Input != 9 {
+		t.Errorf("overrides not applied: %+v", table)
+	}
+	if _, ok := table["gpt-5-mini"]; !ok {
+		t.Error("defaults should be kept")
+	}
+
+	if _, err := ParsePriceTable([]byte(`{"x":{"input":-1}}`)); err == nil {
+		t.Error("negative price should be rejected")
+	}
+	if _, err := ParsePriceTable([]byte(`not json`)); err == nil {
+		t.Error("invalid JSON should be rejected")
+	}
+}
@@ -0,0 +1,446 @@
+// Package fakeapi is an in-process stand-in for the OpenAI Conversations and
+// Responses APIs. It replays scripted model turns so the full tool loop can
+// be exercised offline in tests.
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// Turn is one scripted reply to POST /responses
+type Turn struct {
+	Text  string     // Assistant output_text (may contain markers)
+	Calls []ToolCall // function_call items; more than one = parallel calls
+
+	// Usage, if set, is reported in the response's usage block
+	Usage *Usage
+
+	// Status, if set to a non-200 code, makes the server answer with an
+	// HTTP error instead of a response
+	Status int
+	Body   string
+	Header map[string]string
+
+	// Drop, if set, closes the connection without answering after the
+	// input was stored, like a connection lost mid-response
+	Drop bool
+}
+
+// ToolCall is a scripted function_call item
+type ToolCall struct {
+	CallID    string // Generated when empty
+	Name      string
+	Arguments string // Raw JSON; may be deliberately malformed
+}
+
+// Usage is the token usage reported for a turn
+type Usage struct {
+	Input, Cached, Output, Reasoning int
+}
+
+// WithUsage returns a copy of t that reports the given token usage
+func (t Turn) WithUsage(input, cached, output, reasoning int) Turn {
+	t.Usage = &Usage{Input: input, Cached: cached, Output: output, Reasoning: reasoning}
+	return t
+}
+
+// Text returns a turn that only prints text (ends the tool loop)
+func Text(text string) Turn {
+	return Turn{Text: text}
+}
+
+// Calls returns a turn that requests one or more tool calls
+func Calls(calls ...ToolCall) Turn {
+	return Turn{Calls: calls}
+}
+
+// Call builds a function_call item
+func Call(name, arguments string) ToolCall {
+	return ToolCall{Name: name, Arguments: arguments}
+}
+
+// Error returns a turn answered with an HTTP error
+func Error(status int, body string) Turn {
+	return Turn{Status: status, Body: body}
+}
+
+// Server is a scripted fake API server
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	turns         []Turn
+	createErrors  []Turn
+	requests      []map[string]interface{}
+	conversations []string
+	deleted       []string
+	items         map[string][]interface{}
+	expired       map[string]bool
+	nextCall      int
+}
+
+// New starts a server that answers /responses with turns in order
+func New(turns ...Turn) *Server {
+	s := &Server{turns: turns, items: map[string][]interface{}{}, expired: map[string]bool{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /conversations", s.handleConversations)
+	mux.HandleFunc("DELETE /conversations/{id}", s.handleDeleteConversation)
+	mux.HandleFunc("POST /conversations/{id}/items", s.handleAddItems)
+	mux.HandleFunc("GET /conversations/{id}/items", s.handleListItems)
+	mux.HandleFunc("POST /responses", s.handleResponses)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Push appends more scripted turns
+func (s *Server) Push(turns ...Turn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.turns = append(s.turns, turns...)
+}
+
+// FailConversations makes the next conversation creations answer with the
+// given error turns, one per request, before creations succeed again
+func (s *Server) FailConversations(turns ...Turn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.createErrors = append(s.createErrors, turns...)
+}
+
+// Requests returns the decoded /responses payloads received so far
+func (s *Server) Requests() []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]interface{}(nil), s.requests...)
+}
+
+// Conversations returns the IDs of conversations created so far
+func (s *Server) Conversations() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.conversations...)
+}
+
+// Items returns the items a conversation was created with or had appended
+func (s *Server) Items(conversationID string) []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]interface{}(nil), s.items[conversationID]...)
+}
+
+// Deleted returns the IDs of conversations deleted so far
+func (s *Server) Deleted() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.deleted...)
+}
+
+// Expire makes the server forget conversations or responses by ID, as if
+// they had expired: requests that refer to them get a 404
+func (s *Server) Expire(ids ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		s.expired[id] = true
+	}
+}
+
+// Remaining returns the number of scripted turns not yet consumed
+func (s *Server) Remaining() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.turns)
+}
+
+// FunctionOutputs returns the function_call_output items of request i keyed by call_id
+func (s *Server) FunctionOutputs(i int) map[string]string {
+	reqs := s.Requests()
+	outputs := map[string]string{}
+	if i < 0 || i >= len(reqs) {
+		return outputs
+	}
+	items, _ := reqs[i]["input"].([]interface{})
+	for _, item := range items {
+		m, _ := item.(map[string]interface{})
+		if m["type"] != "function_call_output" {
+			continue
+		}
+		id, _ := m["call_id"].(string)
+		out, _ := m["output"].(string)
+		outputs[id] = out
+	}
+	return outputs
+}
+
+func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Items []interface{} `json:"items"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	s.mu.Lock()
+	if len(s.createErrors) > 0 {
+		turn := s.createErrors[0]
+		s.createErrors = s.createErrors[1:]
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(turn.Status)
+		io.WriteString(w, turn.Body)
+		return
+	}
+	id := fmt.Sprintf("conv_fake_%d", len(s.conversations)+1)
+	s.conversations = append(s.conversations, id)
+	s.items[id] = body.Items
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]interface{}{"id": id, "object": "conversation"})
+}
+
+func (s *Server) handleAddItems(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var body struct {
+		Items []interface{} `json:"items"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Items) > 20 {
+		http.Error(w, `{"error":{"message":"invalid items"}}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	_, known := s.items[id]
+	if known {
+		s.items[id] = append(s.items[id], body.Items...)
+	}
+	s.mu.Unlock()
+
+	if !known {
+		http.Error(w, `{"error":{"message":"conversation not found","param":"conversation"}}`, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"object": "list", "data": body.Items})
+}
+
+// handleListItems lists a conversation's items, oldest first unless
+// order=desc, up to limit
+func (s *Server) handleListItems(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	items, known := s.items[id]
+	items = append([]interface{}{}, items...)
+	s.mu.Unlock()
+
+	if !known {
+		http.Error(w, `{"error":{"message":"conversation not found","param":"conversation"}}`, http.StatusNotFound)
+		return
+	}
+	if r.URL.Query().Get("order") == "desc" {
+		slices.Reverse(items)
+	}
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit < len(items) {
+		items = items[:limit]
+	}
+	writeJSON(w, map[string]interface{}{"object": "list", "data": items})
+}
+
+func (s *Server) handleDeleteConversation(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	known := false
+	for _, c := range s.conversations {
+		known = known || c == id
+	}
+	if known {
+		s.deleted = append(s.deleted, id)
+	}
+	s.mu.Unlock()
+
+	if !known {
+		http.Error(w, `{"error":{"message":"conversation not found","param":"conversation"}}`, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"id": id, "object": "conversation.deleted", "deleted": true})
+}
+
+func (s *Server) handleResponses(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, `{"error":{"message":"invalid JSON body"}}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, payload)
+	conversation, _ := payload["conversation"].(string)
+	previous, _ := payload["previous_response_id"].(string)
+	switch {
+	case s.expired[conversation]:
+		s.mu.Unlock()
+		http.Error(w, fmt.Sprintf(`{"error":{"message":"Conversation with id '%s' not found.","type":"invalid_request_error","param":"conversation"}}`, conversation), http.StatusNotFound)
+		return
+	case s.expired[previous]:
+		s.mu.Unlock()
+		http.Error(w, fmt.Sprintf(`{"error":{"message":"Previous response with id '%s' not found.","type":"invalid_request_error","param":"previous_response_id"}}`, previous), http.StatusNotFound)
+		return
+	}
+	if len(s.turns) == 0 {
+		s.mu.Unlock()
+		http.Error(w, `{"error":{"message":"fakeapi: no scripted turn left"}}`, http.StatusInternalServerError)
+		return
+	}
+	turn := s.turns[0]
+	s.turns = s.turns[1:]
+	responseID := fmt.Sprintf("resp_fake_%d", len(s.requests))
+	output := s.outputItems(turn)
+	failed := turn.Status != 0 && turn.Status != http.StatusOK
+
+	// Like the real API, a stored conversation keeps the input and output
+	_, stored := s.items[conversation]
+	if stored && !failed {
+		switch input := payload["input"].(type) {
+		case string:
+			s.items[conversation] = append(s.items[conversation], map[string]interface{}{"role": "user", "content": input})
+		case []interface{}:
+			s.items[conversation] = append(s.items[conversation], input...)
+		}
+		if !turn.Drop {
+			s.items[conversation] = append(s.items[conversation], output...)
+		}
+	}
+	s.mu.Unlock()
+
+	if turn.Drop {
+		if conn, _, err := http.NewResponseController(w).Hijack(); err == nil {
+			conn.Close()
+		}
+		return
+	}
+	for k, v := range turn.Header {
+		w.Header().Set(k, v)
+	}
+	if failed {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(turn.Status)
+		io.WriteString(w, turn.Body)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"id":     responseID,
+		"object": "response",
+		"status": "completed",
+		"output": output,
+	}
+	if u := turn.Usage; u != nil {
+		resp["usage"] = map[string]interface{}{
+			"input_tokens":          u.Input,
+			"input_tokens_details":  map[string]interface{}{"cached_tokens": u.Cached},
+			"output_tokens":         u.Output,
+			"output_tokens_details": map[string]interface{}{"reasoning_tokens": u.Reasoning},
+			"total_tokens":          u.Input + u.Output,
+		}
+	}
+
+	if stream, _ := payload["stream"].(bool); stream {
+		writeStream(w, resp, output)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// outputItems renders a turn as Responses API output items (caller holds mu)
+func (s *Server) outputItems(turn Turn) []interface{} {
+	output := []interface{}{}
+	if turn.Text != "" {
+		output = append(output, map[string]interface{}{
+			"type": "message",
+			"id":   fmt.Sprintf("msg_fake_%d", len(s.requests)),
+			"role": "assistant",
+			"content": []interface{}{
+				map[string]interface{}{"type": "output_text", "text": turn.Text},
+			},
+		})
+	}
+	for _, call := range turn.Calls {
+		callID := call.CallID
+		if callID == "" {
+			s.nextCall++
+			callID = fmt.Sprintf("call_fake_%d", s.nextCall)
+		}
+		output = append(output, map[string]interface{}{
+			"type":      "function_call",
+			"id":        "fc_" + callID,
+			"call_id":   callID,
+			"name":      call.Name,
+			"arguments": call.Arguments,
+		})
+	}
+	return output
+}
+
+// writeStream emits the response as Server-Sent Events, splitting text and
+// arguments into several deltas like the real API does
+func writeStream(w http.ResponseWriter, resp map[string]interface{}, output []interface{}) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+	send := func(ev map[string]interface{}) {
+		data, _ := json.Marshal(ev)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev["type"], data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	send(map[string]interface{}{"type": "response.created", "response": map[string]interface{}{"id": resp["id"], "status": "in_progress", "output": []interface{}{}}})
+
+	for i, raw := range output {
+		item := raw.(map[string]interface{})
+		switch item["type"] {
+		case "message":
+			text := item["content"].([]interface{})[0].(map[string]interface{})["text"].(string)
+			send(map[string]interface{}{"type": "response.output_item.added", "output_index": i, "item": map[string]interface{}{"type": "message", "id": item["id"], "role": "assistant", "content": []interface{}{}}})
+			send(map[string]interface{}{"type": "response.content_part.added", "output_index": i, "content_index": 0, "part": map[string]interface{}{"type": "output_text", "text": ""}})
+			for _, chunk := range chunks(text) {
+				send(map[string]interface{}{"type": "response.output_text.delta", "output_index": i, "content_index": 0, "delta": chunk})
+			}
+			send(map[string]interface{}{"type": "response.output_text.done", "output_index": i, "content_index": 0, "text": text})
+		case "function_call":
+			args := item["arguments"].(string)
+			send(map[string]interface{}{"type": "response.output_item.added", "output_index": i, "item": map[string]interface{}{"type": "function_call", "id": item["id"], "call_id": item["call_id"], "name": item["name"], "arguments": ""}})
+			for _, chunk := range chunks(args) {
+				send(map[string]interface{}{"type": "response.function_call_arguments.delta", "output_index": i, "delta": chunk})
+			}
+			send(map[string]interface{}{"type": "response.function_call_arguments.done", "output_index": i, "arguments": args})
+		}
+		send(map[string]interface{}{"type": "response.output_item.done", "output_index": i, "item": item})
+	}
+
+	send(map[string]interface{}{"type": "response.completed", "response": resp})
+}
+
+// chunks splits s into a few pieces (on rune boundaries) to exercise delta assembly
+func chunks(s string) []string {
+	const size = 8
+	runes := []rune(s)
+	var out []string
+	for len(runes) > size {
+		out = append(out, string(runes[:size]))
+		runes = runes[size:]
+	}
+	if len(runes) > 0 {
+		out = append(out, string(runes))
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,217 @@
+package fstools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	sniffBytes        = 8 * 1024         // Head of a file inspected for its encoding
+	maxTranscodeBytes = 16 * 1024 * 1024 // Largest non-UTF-8 file decoded in memory
+)
+
+// Text encodings recognized by sniffing
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingLatin1  = "latin-1" // Any file that is neither UTF-8 nor UTF-16 nor binary
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// textEncoding is how a text file is stored on disk
+type textEncoding struct {
+	Name string
+	BOM  bool
+}
+
+// plain reports whether the file is UTF-8 without a BOM, which needs no transcoding
+func (e textEncoding) plain() bool {
+	return e.Name == EncodingUTF8 && !e.BOM
+}
+
+func (e textEncoding) String() string {
+	if e.BOM {
+		return e.Name + " (BOM)"
+	}
+	return e.Name
+}
+
+// sniffEncoding guesses the encoding of a file from its first bytes. A
+// BOM decides; otherwise NUL bytes mean UTF-16 when they fall on every
+// other byte like ASCII text in UTF-16 does, and binary when they do not.
+// Files that are not valid UTF-8 are taken as Latin-1.
+func sniffEncoding(head []byte) (enc textEncoding, binary bool) {
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		return textEncoding{EncodingUTF8, true}, false
+	case bytes.HasPrefix(head, bomUTF16LE):
+		return textEncoding{EncodingUTF16LE, true}, false
+	case bytes.HasPrefix(head, bomUTF16BE):
+		return textEncoding{EncodingUTF16BE, true}, false
+	}
+
+	if bytes.IndexByte(head, 0) >= 0 {
+		// Whole code units only: an odd-sized file or head ends in half a unit
+		units := head[:len(head)&^1]
+		evenZeros, oddZeros := 0, 0
+		for i, b := range units {
+			if b == 0 && i%2 == 0 {
+				evenZeros++
+			} else if b == 0 {
+				oddZeros++
+			}
+		}
+		switch n := len(units) / 2; {
+		case n > 0 && evenZeros == 0 && oddZeros*2 >= n:
+			return textEncoding{Name: EncodingUTF16LE}, false
+		case n > 0 && oddZeros == 0 && evenZeros*2 >= n:
+			return textEncoding{Name: EncodingUTF16BE}, false
+		}
+		return textEncoding{}, true
+	}
+
+	// Control characters other than whitespace are rare in text
+	control := 0
+	for _, b := range head {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != '\b' && b != 0x1b {
+			control++
+		}
+	}
+	if control*10 > len(head) {
+		return textEncoding{}, true
+	}
+
+	if !utf8.Valid(trimPartialRune(head)) {
+		return textEncoding{Name: EncodingLatin1}, false
+	}
+	return textEncoding{Name: EncodingUTF8}, false
+}
+
+// trimPartialRune drops a UTF-8 sequence cut off at the end of a sniffed head
+func trimPartialRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// decodeText converts file content in enc to a UTF-8 string without BOM
+func decodeText(data []byte, enc textEncoding) string {
+	switch enc.Name {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		if enc.BOM && len(data) >= 2 {
+			data = data[2:]
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if enc.Name == EncodingUTF16LE {
+				units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+			} else {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			}
+		}
+		return string(utf16.Decode(units))
+	case EncodingLatin1:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	if enc.BOM {
+		data = bytes.TrimPrefix(data, bomUTF8)
+	}
+	return string(data)
+}
+
+// encodeText converts s back to enc, restoring the BOM
+func encodeText(s string, enc textEncoding) ([]byte, error) {
+	var out []byte
+	switch enc.Name {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		if enc.BOM && enc.Name == EncodingUTF16LE {
+			out = append(out, bomUTF16LE...)
+		} else if enc.BOM {
+			out = append(out, bomUTF16BE...)
+		}
+		for _, u := range utf16.Encode([]rune(s)) {
+			if enc.Name == EncodingUTF16LE {
+				out = append(out, byte(u), byte(u>>8))
+			} else {
+				out = append(out, byte(u>>8), byte(u))
+			}
+		}
+		return out, nil
+	case EncodingLatin1:
+		out = make([]byte, 0, len(s))
+		for _, r := range s {
+			if r > 0xFF {
+				return nil, fmt.Errorf("%q cannot be encoded as %s, the file's encoding", r, EncodingLatin1)
+			}
+			out = append(out, byte(r))
+		}
+		return out, nil
+	}
+	if enc.BOM {
+		out = append(out, bomUTF8...)
+	}
+	return append(out, s...), nil
+}
+
+// textReader sniffs r and returns its content as UTF-8. Plain UTF-8 is
+// streamed; other encodings are decoded in memory up to maxTranscodeBytes.
+// For binary content only the head is read.
+func textReader(r io.Reader) (text io.Reader, enc textEncoding, head []byte, binary bool, err error) {
+	head = make([]byte, sniffBytes)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, enc, nil, false, err
+	}
+	head = head[:n]
+
+	enc, binary = sniffEncoding(head)
+	if binary {
+		return nil, enc, head, true, nil
+	}
+	all := io.MultiReader(bytes.NewReader(head), r)
+	if enc.plain() {
+		return all, enc, head, false, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(all, maxTranscodeBytes+1))
+	if err != nil {
+		return nil, enc, head, false, err
+	}
+	if len(data) > maxTranscodeBytes {
+		return nil, enc, head, false, fmt.Errorf("%s file larger than %d bytes", enc.Name, maxTranscodeBytes)
+	}
+	return bytes.NewReader([]byte(decodeText(data, enc))), enc, head, false, nil
+}
+
+// mimeType guesses a file's type from its content, then its extension
+func mimeType(name string, head []byte) string {
+	detected := http.DetectContentType(head)
+	if detected == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(filepath.Ext(name)); byExt != "" {
+			return byExt
+		}
+	}
+	return detected
+}
@@ -0,0 +1,111 @@
+package fstools
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSniffEncoding(t *testing.T) {
+	utf16le := []byte{'h', 0, 'i', 0, '\n', 0}
+	tests := []struct {
+		name   string
+		head   []byte
+		want   string
+		binary bool
+	}{
+		{"empty", nil, "utf-8", false},
+		{"utf-8", []byte("héllo\n"), "utf-8", false},
+		{"utf-8 bom", append(bytes.Clone(bomUTF8), "x"...), "utf-8 (BOM)", false},
+		{"utf-16le bom", append(bytes.Clone(bomUTF16LE), utf16le...), "utf-16le (BOM)", false},
+		{"utf-16be bom", []byte{0xFE, 0xFF, 0, 'h'}, "utf-16be (BOM)", false},
+		{"utf-16le no bom", utf16le, "utf-16le", false},
+		{"utf-16le odd length", utf16le[:5], "utf-16le", false},
+		{"utf-16be odd length", []byte{0, 'h', 0, 'i', 0}, "utf-16be", false},
+		{"latin-1", []byte("caf\xe9\n"), "latin-1", false},
+		{"utf-8 cut mid-rune", []byte("ab\xc3"), "utf-8", false},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "", true},
+		{"control bytes", []byte("\x01\x02\x03\x04abc"), "", true},
+	}
+	for _, tt := range tests {
+		enc, binary := sniffEncoding(tt.head)
+		if binary != tt.binary || (!binary && enc.String() != tt.want) {
+			t.Errorf("%s: got %v binary=%v, want %s binary=%v", tt.name, enc, binary, tt.want, tt.binary)
+		}
+	}
+}
+
+func TestBinaryAndEncodedFiles(t *testing.T) {
+	repoRoot := t.TempDir()
+	write := func(name string, data []byte) {
+		os.WriteFile(filepath.Join(repoRoot, name), data, 0644)
+	}
+	utf16 := func(s string) []byte {
+		data, _ := encodeText(s, textEncoding{EncodingUTF16LE, true})
+		return data
+	}
+	write("logo.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR needle"))
+	write("notes.txt", utf16("first\nneedle in utf-16\n"))
+	write("legacy.txt", []byte("caf\xe9 needle\n"))
+	write("bom.go", append(bytes.Clone(bomUTF8), "package bom // needle\n"...))
+
+	grep := Grep(repoRoot, GrepOptions{Query: "needle"})
+	want := []string{
+		"bom.go:1:package bom // needle",
+		"legacy.txt:1:café needle",
+		"notes.txt:2:needle in utf-16",
+	}
+	if strings.Join(grep.Results.([]string), "\n") != strings.Join(want, "\n") {
+		t.Errorf("Grep = %q", grep.Results)
+	}
+
+	r := Read(repoRoot, "logo.png", 0, 0, 0)
+	if r.OK || !strings.Contains(r.Error, "binary file (23 bytes, image/png)") {
+		t.Errorf("Read binary = %+v", r)
+	}
+	r = Read(repoRoot, "notes.txt", 0, 0, 0)
+	if !r.OK || r.Content != "000001\tfirst\n000002\tneedle in utf-16" || r.Extra["encoding"] != "utf-16le (BOM)" {
+		t.Errorf("Read utf-16 = %+v", r)
+	}
+	if r = Read(repoRoot, "bom.go", 0, 0, 0); r.Content != "000001\tpackage bom // needle" {
+		t.Errorf("Read BOM = %q", r.Content)
+	}
+
+	// Edit and Write keep the encoding and BOM
+	if r := Edit(repoRoot, "notes.txt", "needle", "pin"); !r.OK {
+		t.Fatalf("Edit utf-16: %s", r.Error)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repoRoot, "notes.txt")); !bytes.Equal(data, utf16("first\npin in utf-16\n")) {
+		t.Errorf("utf-16 after Edit = %q", data)
+	}
+	if r := Edit(repoRoot, "legacy.txt", "needle", "☕"); r.OK || !strings.Contains(r.Error, "cannot be encoded as latin-1") {
+		t.Errorf("Edit latin-1 with non-latin text = %+v", r)
+	}
+	if r := Edit(repoRoot, "legacy.txt", "café", "bar"); !r.OK {
+		t.Fatalf("Edit latin-1: %s", r.Error)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repoRoot, "legacy.txt")); string(data) != "bar needle\n" {
+		t.Errorf("latin-1 after Edit = %q", data)
+	}
+	if r := Write(repoRoot, "bom.go", "package bom\n"); !r.OK || r.Extra["encoding"] != "utf-8 (BOM)" {
+		t.Errorf("Write over BOM file = %+v", r)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repoRoot, "bom.go")); !bytes.HasPrefix(data, bomUTF8) {
+		t.Errorf("BOM lost: %q", data)
+	}
+
+	// Write replaces a file taken as Latin-1 (here CP949) with UTF-8
+	// instead of failing on text Latin-1 cannot hold
+	write("korean.txt", []byte("\xc7\xd1\xb1\xdb\n"))
+	if r := Write(repoRoot, "korean.txt", "한글\n"); !r.OK || r.Extra["encoding"] != nil {
+		t.Errorf("Write over legacy file = %+v", r)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repoRoot, "korean.txt")); string(data) != "한글\n" {
+		t.Errorf("legacy file after Write = %q", data)
+	}
+	if r := Edit(repoRoot, "logo.png", "needle", "pin"); r.OK || r.Error != "Edit: binary file" {
+		t.Errorf("Edit binary = %+v", r)
+	}
+}
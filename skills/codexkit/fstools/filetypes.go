@@ -0,0 +1,67 @@
+package fstools
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// fileTypes maps Grep's type filter to file name globs, after ripgrep's --type
+var fileTypes = map[string][]string{
+	"c":      {"*.c", "*.h"},
+	"cpp":    {"*.cpp", "*.cc", "*.cxx", "*.hpp", "*.hh", "*.hxx", "*.h"},
+	"cs":     {"*.cs", "*.csx"},
+	"css":    {"*.css", "*.scss", "*.sass", "*.less"},
+	"docker": {"Dockerfile", "Dockerfile.*", "*.dockerfile"},
+	"go":     {"*.go"},
+	"html":   {"*.html", "*.htm"},
+	"java":   {"*.java"},
+	"js":     {"*.js", "*.jsx", "*.mjs", "*.cjs"},
+	"json":   {"*.json", "*.jsonc"},
+	"kotlin": {"*.kt", "*.kts"},
+	"make":   {"Makefile", "makefile", "GNUmakefile", "*.mk"},
+	"md":     {"*.md", "*.markdown", "*.mdx"},
+	"php":    {"*.php"},
+	"proto":  {"*.proto"},
+	"py":     {"*.py", "*.pyi"},
+	"ruby":   {"*.rb", "Gemfile", "Rakefile", "*.gemspec"},
+	"rust":   {"*.rs"},
+	"sh":     {"*.sh", "*.bash", "*.zsh"},
+	"sql":    {"*.sql"},
+	"swift":  {"*.swift"},
+	"toml":   {"*.toml"},
+	"ts":     {"*.ts", "*.tsx", "*.mts", "*.cts"},
+	"yaml":   {"*.yaml", "*.yml"},
+}
+
+// typeGlobs returns the name globs of a file type; "" allows every file
+func typeGlobs(fileType string) ([]string, error) {
+	if fileType == "" {
+		return nil, nil
+	}
+	globs, ok := fileTypes[strings.ToLower(fileType)]
+	if !ok {
+		names := make([]string, 0, len(fileTypes))
+		for name := range fileTypes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown type %q (use one of %s)", fileType, strings.Join(names, ", "))
+	}
+	return globs, nil
+}
+
+// matchesType reports whether relPath's file name matches one of globs
+func matchesType(globs []string, relPath string) bool {
+	if globs == nil {
+		return true
+	}
+	name := path.Base(relPath)
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+package fstools
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxBraceAlternatives caps how many patterns one brace expression expands to
+const maxBraceAlternatives = 256
+
+// skippedDirs are never walked unless a pattern names them
+var skippedDirs = []string{".git", "node_modules", ".venv"}
+
+// globPattern is a compiled slash-separated glob. "**" as a whole segment
+// matches zero or more directories, {a,b} expands to alternatives, and
+// every other segment uses path.Match syntax (*, ?, [a-z], [!a-z]).
+type globPattern struct {
+	source string
+	alts   [][]string // Segments of each brace alternative
+}
+
+// compileGlob parses pattern, which is relative to the repo root
+func compileGlob(pattern string) (*globPattern, error) {
+	pattern = strings.TrimPrefix(path.Clean(filepath.ToSlash(pattern)), "./")
+	expanded, err := expandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &globPattern{source: pattern}
+	for _, alt := range expanded {
+		segments := strings.Split(alt, "/")
+		for i, seg := range segments {
+			// Accept the shell's [!...] negation alongside path.Match's [^...]
+			seg = strings.ReplaceAll(seg, "[!", "[^")
+			if _, err := path.Match(seg, ""); err != nil {
+				return nil, fmt.Errorf("bad pattern %q", alt)
+			}
+			segments[i] = seg
+		}
+		g.alts = append(g.alts, segments)
+	}
+	return g, nil
+}
+
+// Match reports whether the slash-separated relative path matches
+func (g *globPattern) Match(relPath string) bool {
+	name := strings.Split(relPath, "/")
+	for _, alt := range g.alts {
+		if matchSegments(alt, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchDir reports whether files below relDir could match, so the walk
+// can skip directories that cannot
+func (g *globPattern) MatchDir(relDir string) bool {
+	dir := strings.Split(relDir, "/")
+	for _, alt := range g.alts {
+		if matchPrefix(alt, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches a path segment by segment
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			pat = pat[1:]
+			if len(pat) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pat, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchPrefix reports whether dir matches the leading segments of pat
+// with at least one segment left over for the files inside it
+func matchPrefix(pat, dir []string) bool {
+	for len(dir) > 0 {
+		if len(pat) == 0 {
+			return false
+		}
+		if pat[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(pat[0], dir[0]); !ok {
+			return false
+		}
+		pat, dir = pat[1:], dir[1:]
+	}
+	return len(pat) > 0
+}
+
+// expandBraces turns a{b,c}d into abd and acd, recursively. A brace
+// without a matching close is literal; a backslash escapes the next byte.
+func expandBraces(pattern string) ([]string, error) {
+	open, depth := -1, 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				open = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+			var out []string
+			for _, choice := range splitAlternatives(pattern[open+1 : i]) {
+				rest, err := expandBraces(pattern[:open] + choice + pattern[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, rest...)
+				if len(out) > maxBraceAlternatives {
+					return nil, fmt.Errorf("pattern expands to more than %d alternatives", maxBraceAlternatives)
+				}
+			}
+			return out, nil
+		}
+	}
+	return []string{pattern}, nil
+}
+
+// splitAlternatives splits the inside of a brace on top-level commas
+func splitAlternatives(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// walkRepo calls fn for every file under repoRoot whose relative path
+// matches g (every file when g is nil). Directories that cannot hold a
+// match are pruned, as are .git, node_modules and .venv unless the
+// pattern names them. Unless includeIgnored is set, files and directories
+// excluded by .gitignore, .git/info/exclude or .codexignore are skipped.
+// Symlinks are reported but never followed. The working directory is
+// never changed.
+func walkRepo(repoRoot string, g *globPattern, includeIgnored bool, fn func(relPath string, d fs.DirEntry) error) error {
+	var ignore *ignoreMatcher
+	if !includeIgnored {
+		ignore = newIgnoreMatcher(repoRoot)
+	}
+
+	return filepath.WalkDir(repoRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if p == repoRoot {
+			return nil
+		}
+		rel, err := filepath.Rel(repoRoot, p)
+		if err != nil {
+			return nil
+		}
+		relPath := filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if skipDir(d.Name(), g) || (g != nil && !g.MatchDir(relPath)) {
+				return filepath.SkipDir
+			}
+			if ignore != nil {
+				if ignore.Ignored(relPath, true) {
+					return filepath.SkipDir
+				}
+				ignore.load(relPath)
+			}
+			return nil
+		}
+		if g != nil && !g.Match(relPath) {
+			return nil
+		}
+		if ignore != nil && ignore.Ignored(relPath, false) {
+			return nil
+		}
+		return fn(relPath, d)
+	})
+}
+
+// skipDir reports whether a default-skipped directory should be pruned
+func skipDir(name string, g *globPattern) bool {
+	for _, skipped := range skippedDirs {
+		if name == skipped {
+			return g == nil || !strings.Contains(g.source, skipped)
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package fstools
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGlobPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/tool/main.go", true},
+		{"src/**/*.ts", "src/a.ts", true},
+		{"src/**/*.ts", "src/lib/deep/a.ts", true},
+		{"src/**/*.ts", "lib/a.ts", false},
+		{"src/**", "src/lib/a.ts", true},
+		{"src/*/index.ts", "src/lib/index.ts", true},
+		{"src/*/index.ts", "src/lib/x/index.ts", false},
+		{"**/*.{ts,tsx}", "ui/Button.tsx", true},
+		{"**/*.{ts,tsx}", "ui/Button.jsx", false},
+		{"{cmd,internal/{a,b}}/*.go", "internal/b/x.go", true},
+		{"{cmd,internal/{a,b}}/*.go", "internal/c/x.go", false},
+		{"test_[0-9].py", "test_7.py", true},
+		{"test_[!0-9].py", "test_7.py", false},
+		{"test_[!0-9].py", "test_x.py", true},
+		{"./docs/*.md", "docs/README.md", true},
+		{"a{b", "a{b", true},
+	}
+	for _, tt := range tests {
+		g, err := compileGlob(tt.pattern)
+		if err != nil {
+			t.Errorf("compileGlob(%q): %v", tt.pattern, err)
+			continue
+		}
+		if got := g.Match(tt.path); got != tt.want {
+			t.Errorf("%q.Match(%q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := compileGlob("src/[a-.go"); err == nil {
+		t.Error("unterminated class should be rejected")
+	}
+	if _, err := compileGlob(strings.Repeat("{a,b,c}", 6)); err == nil {
+		t.Error("brace explosion should be rejected")
+	}
+}
+
+func TestGlobWalksFromRepoRoot(t *testing.T) {
+	repoRoot := t.TempDir()
+	files := []string{"main.go", "cmd/app/main.go", "ui/Button.tsx", "ui/Button.ts", "ui/style.css", "node_modules/x/index.ts", ".env", "secrets/.env.go"}
+	for i, f := range files {
+		p := filepath.Join(repoRoot, filepath.FromSlash(f))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte("package main\n"), 0644)
+		mtime := time.Now().Add(time.Duration(i-len(files)) * time.Hour)
+		os.Chtimes(p, mtime, mtime)
+	}
+
+	results := func(r ToolResult) []string {
+		t.Helper()
+		if !r.OK {
+			t.Fatalf("Glob failed: %s", r.Error)
+		}
+		return r.Results.([]string)
+	}
+
+	if got := results(Glob(repoRoot, "**/*.go", 0, "", false)); !reflect.DeepEqual(got, []string{"cmd/app/main.go", "main.go"}) {
+		t.Errorf("**/*.go = %v", got)
+	}
+	if got := results(Glob(repoRoot, "ui/*.{ts,tsx}", 0, "", false)); !reflect.DeepEqual(got, []string{"ui/Button.ts", "ui/Button.tsx"}) {
+		t.Errorf("braces = %v", got)
+	}
+	if got := results(Glob(repoRoot, "**/*.ts*", 0, SortByMtime, false)); !reflect.DeepEqual(got, []string{"ui/Button.ts", "ui/Button.tsx"}) {
+		t.Errorf("mtime order = %v", got)
+	}
+	if got := results(Glob(repoRoot, "node_modules/**/*.ts", 0, "", false)); !reflect.DeepEqual(got, []string{"node_modules/x/index.ts"}) {
+		t.Errorf("explicit node_modules = %v", got)
+	}
+	if r := Glob(repoRoot, "**/*", 2, "", false); r.Count != 2 || r.Extra["total"] != 5 {
+		t.Errorf("capped glob = %v (total %v)", r.Results, r.Extra["total"])
+	}
+	if r := Glob(repoRoot, "*", 0, "size", false); r.OK {
+		t.Error("unknown sort should fail")
+	}
+
+	// Grep scopes: a bare name pattern matches at any depth
+	grep := Grep(repoRoot, GrepOptions{Query: "package", Glob: "*.go"})
+	if grep.Count != 2 || !strings.HasPrefix(grep.Results.([]string)[0], "cmd/app/main.go:1:") {
+		t.Errorf("Grep *.go = %v", grep.Results)
+	}
+	if grep := Grep(repoRoot, GrepOptions{Query: "package", Glob: "ui/**"}); grep.Count != 3 {
+		t.Errorf("Grep ui/** = %v", grep.Results)
+	}
+}
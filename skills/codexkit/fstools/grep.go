@@ -0,0 +1,342 @@
+package fstools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Grep output modes
+const (
+	GrepContent     = "content"            // Matching lines as path:line:text (default)
+	GrepFiles       = "files_with_matches" // Paths of files with at least one match
+	GrepCount       = "count"              // path:count per file with matches
+	maxContextLines = 10
+	maxGrepWorkers  = 8 // Files searched at once; reads overlap even on one CPU
+)
+
+// GrepOptions configures a Grep search
+type GrepOptions struct {
+	Query      string
+	Glob       string // Optional scope; without a slash it matches names at any depth
+	Regex      bool   // Query is an RE2 regular expression
+	IgnoreCase bool
+	WholeWord  bool
+	Before     int    // Context lines before each match (content mode, <= 10)
+	After      int    // Context lines after each match (content mode, <= 10)
+	OutputMode string // GrepContent (default), GrepFiles or GrepCount
+	MaxResults int    // Max matching lines, or files in the other modes (<= 200)
+	Type       string // Optional file type such as go, ts or py (see fileTypes)
+
+	// IncludeIgnored also searches files excluded by .gitignore,
+	// .git/info/exclude and .codexignore
+	IncludeIgnored bool
+}
+
+// Grep searches for text in files. In content mode context lines are
+// reported as path-line-text and non-adjacent groups are separated by "--".
+// Files are searched concurrently; results keep the walk's order.
+func Grep(repoRoot string, opts GrepOptions) ToolResult {
+	return grep(repoRoot, opts, maxGrepWorkers)
+}
+
+// grep is Grep with a given number of file workers
+func grep(repoRoot string, opts GrepOptions, workers int) ToolResult {
+	if opts.Query == "" {
+		return ToolResult{OK: false, Error: "Grep: query required"}
+	}
+
+	if opts.MaxResults <= 0 || opts.MaxResults > DefaultMaxResults {
+		opts.MaxResults = DefaultMaxResults
+	}
+	if opts.OutputMode == "" {
+		opts.OutputMode = GrepContent
+	}
+	switch opts.OutputMode {
+	case GrepContent, GrepFiles, GrepCount:
+	default:
+		return ToolResult{OK: false, Error: fmt.Sprintf("Grep: unknown output_mode %q (use content, files_with_matches or count)", opts.OutputMode)}
+	}
+	if opts.OutputMode != GrepContent {
+		opts.Before, opts.After = 0, 0
+	}
+	opts.Before = min(max(opts.Before, 0), maxContextLines)
+	opts.After = min(max(opts.After, 0), maxContextLines)
+
+	types, err := typeGlobs(opts.Type)
+	if err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Grep: %v", err)}
+	}
+
+	match, err := lineMatcher(opts)
+	if err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Grep: %v", err)}
+	}
+
+	// A glob without a slash matches file names at any depth, like "*.go"
+	var g *globPattern
+	if opts.Glob != "" {
+		if err := requireSafePath(opts.Glob); err != nil {
+			return ToolResult{OK: false, Error: fmt.Sprintf("Grep: invalid glob: %v", err)}
+		}
+		scope := opts.Glob
+		if !strings.Contains(scope, "/") {
+			scope = "**/" + scope
+		}
+		if g, err = compileGlob(scope); err != nil {
+			return ToolResult{OK: false, Error: fmt.Sprintf("Grep: invalid glob: %v", err)}
+		}
+	}
+
+	// The walk feeds files to workers in order; every file yields one
+	// result, so the collector can put them back in that order. Once
+	// enough matches are in, the walk and the workers stop early.
+	type fileJob struct {
+		index   int
+		relPath string
+	}
+	type fileResult struct {
+		index   int
+		relPath string
+		lines   []string
+		found   int
+	}
+	jobs := make(chan fileJob)
+	out := make(chan fileResult)
+	var done atomic.Bool
+
+	go func() {
+		defer close(jobs)
+		index := 0
+		walkRepo(repoRoot, g, opts.IncludeIgnored, func(relPath string, d fs.DirEntry) error {
+			if done.Load() {
+				return fs.SkipAll
+			}
+			if IsDeniedPath(relPath) || !matchesType(types, relPath) {
+				return nil
+			}
+
+			// Skip symlinks
+			if d.Type()&fs.ModeSymlink != 0 {
+				return nil
+			}
+
+			jobs <- fileJob{index, relPath}
+			index++
+			return nil
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				res := fileResult{index: job.index, relPath: job.relPath}
+				if !done.Load() {
+					res.lines, res.found = grepFile(repoRoot, job.relPath, match, opts, opts.MaxResults)
+				}
+				out <- res
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	results := []string{}
+	found := 0 // Matching lines (content) or files (other modes)
+	pending := map[int]fileResult{}
+	next := 0
+	for res := range out {
+		pending[res.index] = res
+		for ; !done.Load(); next++ {
+			res, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			if res.found == 0 {
+				continue
+			}
+
+			// The last file may hold more matches than are still wanted
+			if left := opts.MaxResults - found; res.found > left {
+				res.lines, res.found = grepFile(repoRoot, res.relPath, match, opts, left)
+			}
+			if len(results) > 0 && (opts.Before > 0 || opts.After > 0) {
+				results = append(results, "--")
+			}
+			results = append(results, res.lines...)
+			found += res.found
+			if found >= opts.MaxResults {
+				done.Store(true)
+			}
+		}
+	}
+
+	return ToolResult{
+		OK:      true,
+		Tool:    "Grep",
+		Results: results,
+		Count:   found,
+		Extra: map[string]interface{}{
+			"repo_root":   repoRoot,
+			"query":       opts.Query,
+			"glob":        opts.Glob,
+			"output_mode": opts.OutputMode,
+		},
+	}
+}
+
+// lineMatcher builds the per-line test for opts. Plain case-sensitive
+// searches use bytes.Contains; everything else compiles to RE2.
+func lineMatcher(opts GrepOptions) (func([]byte) bool, error) {
+	if !opts.Regex && !opts.IgnoreCase && !opts.WholeWord {
+		query := []byte(opts.Query)
+		return func(line []byte) bool { return bytes.Contains(line, query) }, nil
+	}
+
+	expr := opts.Query
+	if !opts.Regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if opts.WholeWord {
+		expr = `\b(?:` + expr + `)\b`
+	}
+	if opts.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %v", err)
+	}
+	return re.Match, nil
+}
+
+// grepFile searches one file, returning up to limit matches (matching
+// lines in content mode, otherwise 1 for a matching file) and the lines
+// to report. Large, unreadable and binary files have no matches.
+func grepFile(repoRoot, relPath string, match func([]byte) bool, opts GrepOptions, limit int) ([]string, int) {
+	// SECURITY: Open with protection
+	file, err := openSecure(repoRoot, relPath, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, 0
+	}
+	defer file.Close()
+
+	// Skip large files
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxGrepFileSize {
+		return nil, 0
+	}
+
+	// Skip binary files; search other encodings as UTF-8
+	text, _, _, binary, err := textReader(file)
+	if err != nil || binary {
+		return nil, 0
+	}
+
+	switch opts.OutputMode {
+	case GrepFiles:
+		if countMatches(text, match, true) > 0 {
+			return []string{relPath}, 1
+		}
+	case GrepCount:
+		if n := countMatches(text, match, false); n > 0 {
+			return []string{fmt.Sprintf("%s:%d", relPath, n)}, 1
+		}
+	default:
+		return grepLines(text, relPath, match, opts, limit)
+	}
+	return nil, 0
+}
+
+// grepLines returns a file's matching lines with context, stopping after
+// limit matches once the last one's trailing context is out
+func grepLines(text io.Reader, relPath string, match func([]byte) bool, opts GrepOptions, limit int) ([]string, int) {
+	type line struct {
+		num  int
+		text string
+	}
+	var results []string
+	var before []line // Up to opts.Before most recent unprinted lines
+	printed := 0      // Last line number added to results
+	afterLeft := 0
+	found := 0
+
+	context := opts.Before > 0 || opts.After > 0
+	emit := func(num int, text string, sep string) {
+		// Separate groups that are not adjacent
+		if context && printed > 0 && num > printed+1 {
+			results = append(results, "--")
+		}
+		results = append(results, fmt.Sprintf("%s%s%d%s%s", relPath, sep, num, sep, text))
+		printed = num
+	}
+
+	scanner := bufio.NewScanner(text)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // 1MB line limit
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		raw := scanner.Bytes()
+		full := found >= limit
+		if full && afterLeft == 0 {
+			break
+		}
+
+		// Lines become strings only when they may be reported
+		if !full && match(raw) {
+			for _, l := range before {
+				emit(l.num, l.text, "-")
+			}
+			before = before[:0]
+			emit(lineNum, string(raw), ":")
+			found++
+			afterLeft = opts.After
+			continue
+		}
+
+		if afterLeft > 0 {
+			emit(lineNum, string(raw), "-")
+			afterLeft--
+			continue
+		}
+		if opts.Before > 0 {
+			if len(before) == opts.Before {
+				before = before[1:]
+			}
+			before = append(before, line{lineNum, string(raw)})
+		}
+	}
+
+	// Ignore scanner errors (file read errors shouldn't stop entire grep)
+	_ = scanner.Err()
+	return results, found
+}
+
+// countMatches counts matching lines, stopping at the first if firstOnly
+func countMatches(text io.Reader, match func([]byte) bool, firstOnly bool) int {
+	scanner := bufio.NewScanner(text)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // 1MB line limit
+	n := 0
+	for scanner.Scan() {
+		if match(scanner.Bytes()) {
+			n++
+			if firstOnly {
+				break
+			}
+		}
+	}
+	return n
+}
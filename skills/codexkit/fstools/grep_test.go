@@ -0,0 +1,187 @@
+package fstools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGrepOptions(t *testing.T) {
+	repoRoot := t.TempDir()
+	os.MkdirAll(filepath.Join(repoRoot, "api"), 0755)
+	os.WriteFile(filepath.Join(repoRoot, "api/handlers.go"), []byte(strings.Join([]string{
+		"package api",
+		"",
+		"func UserHandler() {}",
+		"func userHandlerHelper() {}",
+		"// Handler wiring",
+		"func OrderHandler() {}",
+		"",
+		"",
+		"",
+		"var handler = 1",
+	}, "\n")), 0644)
+	os.WriteFile(filepath.Join(repoRoot, "README.md"), []byte("Handler docs\n"), 0644)
+	os.WriteFile(filepath.Join(repoRoot, ".env"), []byte("HANDLER_SECRET=x\n"), 0644)
+
+	grep := func(opts GrepOptions) []string {
+		t.Helper()
+		r := Grep(repoRoot, opts)
+		if !r.OK {
+			t.Fatalf("Grep(%+v): %s", opts, r.Error)
+		}
+		return r.Results.([]string)
+	}
+
+	tests := []struct {
+		name string
+		opts GrepOptions
+		want []string
+	}{
+		{"regex", GrepOptions{Query: `func \w+Handler\(`, Regex: true}, []string{
+			"api/handlers.go:3:func UserHandler() {}",
+			"api/handlers.go:6:func OrderHandler() {}",
+		}},
+		{"ignore case whole word", GrepOptions{Query: "handler", IgnoreCase: true, WholeWord: true, Glob: "*.go"}, []string{
+			"api/handlers.go:5:// Handler wiring",
+			"api/handlers.go:10:var handler = 1",
+		}},
+		{"literal is not a regex", GrepOptions{Query: "()"}, []string{
+			"api/handlers.go:3:func UserHandler() {}",
+			"api/handlers.go:4:func userHandlerHelper() {}",
+			"api/handlers.go:6:func OrderHandler() {}",
+		}},
+		{"context", GrepOptions{Query: "Order", Before: 1, After: 1}, []string{
+			"api/handlers.go-5-// Handler wiring",
+			"api/handlers.go:6:func OrderHandler() {}",
+			"api/handlers.go-7-",
+		}},
+		{"context groups", GrepOptions{Query: "User", After: 1, MaxResults: 1}, []string{
+			"api/handlers.go:3:func UserHandler() {}",
+			"api/handlers.go-4-func userHandlerHelper() {}",
+		}},
+		{"files", GrepOptions{Query: "handler", IgnoreCase: true, OutputMode: GrepFiles}, []string{
+			"README.md",
+			"api/handlers.go",
+		}},
+		{"count", GrepOptions{Query: "Handler", OutputMode: GrepCount}, []string{
+			"README.md:1",
+			"api/handlers.go:4",
+		}},
+	}
+	for _, tt := range tests {
+		if got := grep(tt.opts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Separate groups are marked, across files too
+	got := grep(GrepOptions{Query: "andler", After: 1, Glob: "{README.md,api/*.go}"})
+	if strings.Join(got, "\n") != strings.Join([]string{
+		"README.md:1:Handler docs",
+		"--",
+		"api/handlers.go:3:func UserHandler() {}",
+		"api/handlers.go:4:func userHandlerHelper() {}",
+		"api/handlers.go:5:// Handler wiring",
+		"api/handlers.go:6:func OrderHandler() {}",
+		"api/handlers.go-7-",
+		"--",
+		"api/handlers.go:10:var handler = 1",
+	}, "\n") {
+		t.Errorf("groups:\n%s", strings.Join(got, "\n"))
+	}
+
+	if r := Grep(repoRoot, GrepOptions{Query: "func (", Regex: true}); r.OK || !strings.Contains(r.Error, "invalid regex") {
+		t.Errorf("bad regex = %+v", r)
+	}
+	if r := Grep(repoRoot, GrepOptions{Query: "x", OutputMode: "lines"}); r.OK {
+		t.Error("unknown output mode should fail")
+	}
+}
+
+// makeTree writes dirs*files Go and TypeScript files of lines lines each;
+// every tenth line of the Go files contains "needle"
+func makeTree(tb testing.TB, dirs, files, lines int) string {
+	tb.Helper()
+	repoRoot := tb.TempDir()
+	var src strings.Builder
+	for i := 0; i < lines; i++ {
+		if i%10 == 0 {
+			fmt.Fprintf(&src, "var needle%d = %d\n", i, i)
+		} else {
+			fmt.Fprintf(&src, "func filler%d() { return strings.Repeat(\"x\", %d) }\n", i, i)
+		}
+	}
+	for d := 0; d < dirs; d++ {
+		dir := filepath.Join(repoRoot, fmt.Sprintf("pkg%03d", d))
+		os.MkdirAll(dir, 0755)
+		for f := 0; f < files; f++ {
+			os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%03d.go", f)), []byte(src.String()), 0644)
+			os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%03d.ts", f)), []byte("const needle = 1\n"), 0644)
+		}
+	}
+	return repoRoot
+}
+
+func TestGrepParallelKeepsOrder(t *testing.T) {
+	repoRoot := makeTree(t, 10, 10, 50)
+
+	for _, opts := range []GrepOptions{
+		{Query: "needle", MaxResults: 200},
+		{Query: "needle", MaxResults: 7, After: 1},
+		{Query: "needle", OutputMode: GrepFiles, MaxResults: 15},
+		{Query: "needle", OutputMode: GrepCount, Type: "ts"},
+	} {
+		want := grep(repoRoot, opts, 1)
+		for i := 0; i < 5; i++ {
+			got := grep(repoRoot, opts, 8)
+			if got.Count != want.Count || !reflect.DeepEqual(got.Results, want.Results) {
+				t.Fatalf("%+v: parallel results differ from sequential:\n got %q\nwant %q", opts, got.Results, want.Results)
+			}
+		}
+	}
+
+	r := Grep(repoRoot, GrepOptions{Query: "needle", MaxResults: 7})
+	lines := r.Results.([]string)
+	if r.Count != 7 || lines[0] != "pkg000/file000.go:1:var needle0 = 0" || lines[5] != "pkg000/file000.ts:1:const needle = 1" || lines[6] != "pkg000/file001.go:1:var needle0 = 0" {
+		t.Errorf("first matches = %q", lines)
+	}
+
+	r = Grep(repoRoot, GrepOptions{Query: "needle", Type: "ts", OutputMode: GrepFiles})
+	if r.Count != 100 || !strings.HasSuffix(r.Results.([]string)[0], ".ts") {
+		t.Errorf("type ts = %d files, first %q", r.Count, r.Results.([]string)[0])
+	}
+	if r := Grep(repoRoot, GrepOptions{Query: "needle", Type: "cobol"}); r.OK || !strings.Contains(r.Error, "unknown type") {
+		t.Errorf("unknown type = %+v", r)
+	}
+}
+
+// BenchmarkGrep searches a synthetic tree of 2000 Go files (~60MB) with
+// one worker and with the default pool: a query that matches nothing
+// reads every file, one that matches often stops after max_results
+func BenchmarkGrep(b *testing.B) {
+	repoRoot := makeTree(b, 40, 50, 600)
+
+	for _, query := range []struct {
+		name  string
+		regex string
+		count int
+	}{
+		{"full-scan", `absent\d+`, 0},
+		{"early-exit", `needle5\d0 `, DefaultMaxResults},
+	} {
+		for _, workers := range []int{1, maxGrepWorkers} {
+			b.Run(fmt.Sprintf("%s/workers=%d", query.name, workers), func(b *testing.B) {
+				opts := GrepOptions{Query: query.regex, Regex: true, Type: "go"}
+				for i := 0; i < b.N; i++ {
+					if r := grep(repoRoot, opts, workers); r.Count != query.count {
+						b.Fatalf("count = %d", r.Count)
+					}
+				}
+			})
+		}
+	}
+}
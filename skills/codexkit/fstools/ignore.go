@@ -0,0 +1,132 @@
+package fstools
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFiles are read from every directory the walk enters, in order;
+// later files take precedence. .codexignore hides files from the tools
+// only, without touching what git tracks.
+var ignoreFiles = []string{".gitignore", ".codexignore"}
+
+// ignoreRule is one gitignore line
+type ignoreRule struct {
+	glob    *globPattern
+	negate  bool // "!pattern" re-includes
+	dirOnly bool // "pattern/" matches directories only
+}
+
+// ignoreMatcher applies gitignore rules from .git/info/exclude and the
+// ignore files of each walked directory. Rules are relative to the
+// directory holding them; the last matching rule wins, with deeper
+// directories overriding their parents.
+type ignoreMatcher struct {
+	root  string
+	rules map[string][]ignoreRule // By slash-separated directory ("" is the root)
+}
+
+// newIgnoreMatcher loads the repo-wide rules; directories are added with
+// load as the walk reaches them
+func newIgnoreMatcher(repoRoot string) *ignoreMatcher {
+	m := &ignoreMatcher{root: repoRoot, rules: map[string][]ignoreRule{}}
+	m.rules[""] = readIgnoreFile(filepath.Join(repoRoot, ".git", "info", "exclude"))
+	m.load("")
+	return m
+}
+
+// load reads the ignore files of relDir
+func (m *ignoreMatcher) load(relDir string) {
+	dir := filepath.Join(m.root, filepath.FromSlash(relDir))
+	for _, name := range ignoreFiles {
+		m.rules[relDir] = append(m.rules[relDir], readIgnoreFile(filepath.Join(dir, name))...)
+	}
+}
+
+// Ignored reports whether relPath is excluded by the rules of its
+// ancestor directories
+func (m *ignoreMatcher) Ignored(relPath string, isDir bool) bool {
+	ignored := false
+	dir := ""
+	rest := relPath
+	for {
+		for _, rule := range m.rules[dir] {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.glob.Match(rest) {
+				ignored = !rule.negate
+			}
+		}
+		first, remainder, ok := strings.Cut(rest, "/")
+		if !ok {
+			return ignored
+		}
+		if dir == "" {
+			dir = first
+		} else {
+			dir += "/" + first
+		}
+		rest = remainder
+	}
+}
+
+// readIgnoreFile parses a gitignore-format file; a missing file has no rules
+func readIgnoreFile(name string) []ignoreRule {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreLine converts one gitignore line into a rule
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// A slash at the start or in the middle anchors the pattern to the
+	// ignore file's directory; otherwise it matches at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	if !anchored {
+		line = "**/" + line
+	}
+
+	// gitignore has no brace alternatives
+	line = strings.NewReplacer("{", `\{`, "}", `\}`).Replace(line)
+	glob, err := compileGlob(line)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.glob = glob
+	return rule, true
+}
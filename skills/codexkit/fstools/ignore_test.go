@@ -0,0 +1,62 @@
+package fstools
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWalkHonorsIgnoreFiles(t *testing.T) {
+	repoRoot := t.TempDir()
+	files := map[string]string{
+		".gitignore":          "# build output\n/dist/\n*.log\n!keep.log\ngen/\n",
+		".git/info/exclude":   "scratch.txt\n",
+		".codexignore":        "fixtures/big/\n",
+		"main.go":             "x",
+		"debug.log":           "x",
+		"keep.log":            "x",
+		"scratch.txt":         "x",
+		"dist/app.js":         "x",
+		"web/dist/app.js":     "x", // /dist/ is anchored to the root
+		"web/gen/types.ts":    "x",
+		"web/.gitignore":      "*.snap\n!important.snap\n",
+		"web/a.snap":          "x",
+		"web/important.snap":  "x",
+		"fixtures/big/data":   "x",
+		"fixtures/small/data": "x",
+		"{literal}.txt":       "x",
+	}
+	for name, content := range files {
+		p := filepath.Join(repoRoot, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte(content), 0644)
+	}
+
+	got := Glob(repoRoot, "**", 0, "", false).Results
+	want := []string{
+		".codexignore",
+		".gitignore",
+		"fixtures/small/data",
+		"keep.log",
+		"main.go",
+		"web/.gitignore",
+		"web/dist/app.js",
+		"web/important.snap",
+		"{literal}.txt",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Glob honoring ignores:\n got %q\nwant %q", got, want)
+	}
+
+	if r := Glob(repoRoot, "**", 0, "", true); r.Count != len(files)-1 { // .git is never walked
+		t.Errorf("include_ignored found %d files: %q", r.Count, r.Results)
+	}
+
+	if r := Grep(repoRoot, GrepOptions{Query: "^x$", Regex: true, OutputMode: GrepFiles}); r.Count != 6 {
+		t.Errorf("Grep honoring ignores = %q", r.Results)
+	}
+	if r := Grep(repoRoot, GrepOptions{Query: "^x$", Regex: true, OutputMode: GrepFiles, IncludeIgnored: true}); r.Count != 12 {
+		t.Errorf("Grep include_ignored = %q", r.Results)
+	}
+}
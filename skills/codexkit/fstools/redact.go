@@ -0,0 +1,66 @@
+package fstools
+
+import (
+	"encoding/json"
+	"regexp"
+)
+
+// Redacted replaces sensitive values in logs and transcripts
+const Redacted = "[REDACTED]"
+
+var (
+	// Credential formats that are unambiguous on their own
+	secretValueREs = []*regexp.Regexp{
+		regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+		regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),                                         // OpenAI / Anthropic API keys
+		regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),                                            // AWS access key IDs
+		regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),                                  // GitHub tokens
+		regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`),                                 // Slack tokens
+		regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`), // JWTs
+	}
+
+	// key = value assignments whose value is kept out of transcripts
+	secretAssignRE = regexp.MustCompile(`(?i)((?:password|passwd|secret|api[_-]?key|access[_-]?token|auth[_-]?token|private[_-]?key)["']?\s*[:=]\s*\\?["']?)[^\s"'\\,;]{6,}`)
+	bearerRE       = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/-]{16,}=*`)
+)
+
+// RedactSecrets masks credentials and secret assignments in s
+func RedactSecrets(s string) string {
+	for _, re := range secretValueREs {
+		s = re.ReplaceAllString(s, Redacted)
+	}
+	s = secretAssignRE.ReplaceAllString(s, "${1}"+Redacted)
+	return bearerRE.ReplaceAllString(s, "${1}"+Redacted)
+}
+
+// RedactArguments masks a tool call's arguments for logging: file content
+// aimed at a denylisted path is dropped entirely, and secrets are masked
+func RedactArguments(arguments string) string {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(arguments), &args); err == nil {
+		if path, _ := args["path"].(string); path != "" && IsDeniedPath(path) {
+			redactContent(args)
+			// MultiEdit carries its strings in a list of edits
+			if edits, ok := args["edits"].([]interface{}); ok {
+				for _, edit := range edits {
+					if edit, ok := edit.(map[string]interface{}); ok {
+						redactContent(edit)
+					}
+				}
+			}
+			if data, err := json.Marshal(args); err == nil {
+				arguments = string(data)
+			}
+		}
+	}
+	return RedactSecrets(arguments)
+}
+
+// redactContent masks the file content fields of one set of arguments
+func redactContent(args map[string]interface{}) {
+	for _, key := range []string{"content", "old_string", "new_string"} {
+		if _, ok := args[key]; ok {
+			args[key] = Redacted
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package fstools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Args holds decoded function_call arguments
+type Args map[string]interface{}
+
+// String returns a string argument or "" if missing or mistyped
+func (a Args) String(key string) string {
+	s, _ := a[key].(string)
+	return s
+}
+
+// Int returns an integer argument or 0 if missing or mistyped (JSON numbers decode as float64)
+func (a Args) Int(key string) int {
+	f, _ := a[key].(float64)
+	return int(f)
+}
+
+// Bool returns a boolean argument or false if missing or mistyped
+func (a Args) Bool(key string) bool {
+	b, _ := a[key].(bool)
+	return b
+}
+
+// Edits decodes a list of MultiEdit replacements; missing means none
+func (a Args) Edits(key string) ([]EditOp, error) {
+	var edits []EditOp
+	if a[key] == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(a[key])
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &edits); err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+// Tool declares a tool once: its schema, argument decoding and handler
+type Tool struct {
+	Name        string
+	Description string
+	Properties  map[string]interface{}
+	Required    []string
+	Mutates     bool // Writes the file named by ToolResult.Path
+	Run         func(repoRoot string, args Args) ToolResult
+}
+
+// Registry is the single source of truth for advertised and executable tools
+type Registry []Tool
+
+var (
+	globTool = Tool{
+		Name:        "Glob",
+		Description: "Find repository files matching a glob pattern relative to repo root. Supports ** (any depth), {a,b} alternatives and [a-z] classes.",
+		Properties: map[string]interface{}{
+			"pattern": map[string]interface{}{
+				"type":        "string",
+				"description": "Glob like src/**/*.{ts,tsx} (relative to repo root)",
+			},
+			"max_results": map[string]interface{}{
+				"type":        "integer",
+				"description": "Max results (<=200). Default 200.",
+			},
+			"sort": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{SortByPath, SortByMtime},
+				"description": "path (default) or mtime (most recently modified first).",
+			},
+			"include_ignored": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Also include files excluded by .gitignore or .codexignore. Default false.",
+			},
+		},
+		Required: []string{"pattern"},
+		Run: func(repoRoot string, args Args) ToolResult {
+			return Glob(repoRoot, args.String("pattern"), args.Int("max_results"), args.String("sort"), args.Bool("include_ignored"))
+		},
+	}
+
+	grepTool = Tool{
+		Name:        "Grep",
+		Description: "Search for text or a regex in repository files; optionally restrict to a glob.",
+		Properties: map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "Search text, or an RE2 regular expression with regex=true.",
+			},
+			"glob": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional file glob scope like src/**/*.ts; without a slash (*.go) it matches file names at any depth",
+			},
+			"type": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional file type filter: go, ts, js, py, rust, java, c, cpp, cs, ruby, php, md, json, yaml, sh, ...",
+			},
+			"regex": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Treat query as an RE2 regular expression (e.g. func \\w+Handler). Default false.",
+			},
+			"ignore_case": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Case-insensitive match. Default false.",
+			},
+			"whole_word": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Match only at word boundaries. Default false.",
+			},
+			"before": map[string]interface{}{
+				"type":        "integer",
+				"description": "Context lines before each match (<=10, content mode).",
+			},
+			"after": map[string]interface{}{
+				"type":        "integer",
+				"description": "Context lines after each match (<=10, content mode).",
+			},
+			"output_mode": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{GrepContent, GrepFiles, GrepCount},
+				"description": "content (path:line:text, default), files_with_matches (paths) or count (path:count).",
+			},
+			"max_results": map[string]interface{}{
+				"type":        "integer",
+				"description": "Max matches, or files in the other modes (<=200). Default 200.",
+			},
+			"include_ignored": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Also include files excluded by .gitignore or .codexignore. Default false.",
+			},
+		},
+		Required: []string{"query"},
+		Run: func(repoRoot string, args Args) ToolResult {
+			return Grep(repoRoot, GrepOptions{
+				Query:          args.String("query"),
+				Glob:           args.String("glob"),
+				Regex:          args.Bool("regex"),
+				IgnoreCase:     args.Bool("ignore_case"),
+				WholeWord:      args.Bool("whole_word"),
+				Before:         args.Int("before"),
+				After:          args.Int("after"),
+				OutputMode:     args.String("output_mode"),
+				MaxResults:     args.Int("max_results"),
+				Type:           args.String("type"),
+				IncludeIgnored: args.Bool("include_ignored"),
+			})
+		},
+	}
+
+	readTool = Tool{
+		Name:        "Read",
+		Description: "Read a file snippet by line range (relative path).",
+		Properties: map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Relative file path from repo root.",
+			},
+			"start_line": map[string]interface{}{
+				"type":        "integer",
+				"description": "1-based start line. Default 1.",
+			},
+			"end_line": map[string]interface{}{
+				"type":        "integer",
+				"description": "1-based end line (inclusive).",
+			},
+			"max_lines": map[string]interface{}{
+				"type":        "integer",
+				"description": "Max lines to return (<=400). Default 400.",
+			},
+		},
+		Required: []string{"path"},
+		Run: func(repoRoot string, args Args) ToolResult {
+			return Read(repoRoot, args.String("path"), args.Int("start_line"), args.Int("end_line"), args.Int("max_lines"))
+		},
+	}
+
+	writeTool = Tool{
+		Name:        "Write",
+		Description: "Create or overwrite a file with content. Creates parent directories if needed.",
+		Properties: map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Relative file path from repo root.",
+			},
+			"content": map[string]interface{}{
+				"type":        "string",
+				"description": "Full file content to write.",
+			},
+		},
+		Required: []string{"path", "content"},
+		Mutates:  true,
+		Run: func(repoRoot string, args Args) ToolResult {
+			return Write(repoRoot, args.String("path"), args.String("content"))
+		},
+	}
+
+	editTool = Tool{
+		Name:        "Edit",
+		Description: "Edit a file by replacing exact string match. Old string must appear exactly once.",
+		Properties: map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Relative file path from repo root.",
+			},
+			"old_string": map[string]interface{}{
+				"type":        "string",
+				"description": "Exact string to replace (must be unique in file).",
+			},
+			"new_string": map[string]interface{}{
+				"type":        "string",
+				"description": "New string to replace with.",
+			},
+		},
+		Required: []string{"path", "old_string", "new_string"},
+		Mutates:  true,
+		Run: func(repoRoot string, args Args) ToolResult {
+			return Edit(repoRoot, args.String("path"), args.String("old_string"), args.String("new_string"))
+		},
+	}
+
+	multiEditTool = Tool{
+		Name:        "MultiEdit",
+		Description: "Apply several exact string replacements to one file in order, all or nothing. Prefer it over repeated Edit calls on the same file.",
+		Properties: map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Relative file path from repo root.",
+			},
+			"edits": map[string]interface{}{
+				"type":        "array",
+				"description": "Replacements applied in order, each to the result of the previous ones. If any fails, the file is left unchanged.",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"old_string": map[string]interface{}{
+							"type":        "string",
+							"description": "Exact string to replace (must be unique unless replace_all).",
+						},
+						"new_string": map[string]interface{}{
+							"type":        "string",
+							"description": "New string to replace with.",
+						},
+						"replace_all": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Replace every occurrence. Default false.",
+						},
+					},
+					"required": []string{"old_string", "new_string"},
+				},
+			},
+		},
+		Required: []string{"path", "edits"},
+		Mutates:  true,
+		Run: func(repoRoot string, args Args) ToolResult {
+			edits, err := args.Edits("edits")
+			if err != nil {
+				return ToolResult{OK: false, Error: fmt.Sprintf("MultiEdit: invalid edits: %v", err)}
+			}
+			return MultiEdit(repoRoot, args.String("path"), edits)
+		},
+	}
+)
+
+// ReadOnlyTools returns the tools that never modify the repository
+func ReadOnlyTools() Registry {
+	return Registry{globTool, grepTool, readTool}
+}
+
+// ReadWriteTools returns every tool, including Write, Edit and MultiEdit
+func ReadWriteTools() Registry {
+	return Registry{globTool, grepTool, readTool, writeTool, editTool, multiEditTool}
+}
+
+// Names returns the registered tool names in order
+func (r Registry) Names() []string {
+	names := make([]string, 0, len(r))
+	for _, t := range r {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// Mutates reports whether the named tool modifies files
+func (r Registry) Mutates(toolName string) bool {
+	for _, t := range r {
+		if t.Name == toolName {
+			return t.Mutates
+		}
+	}
+	return false
+}
+
+// Schema returns OpenAI function tool definitions built from the registry
+func (r Registry) Schema() []map[string]interface{} {
+	schema := make([]map[string]interface{}, 0, len(r))
+	for _, t := range r {
+		schema = append(schema, map[string]interface{}{
+			"type":        "function",
+			"name":        t.Name,
+			"description": t.Description,
+			"parameters": map[string]interface{}{
+				"type":       "object",
+				"properties": t.Properties,
+				"required":   t.Required,
+			},
+		})
+	}
+	return schema
+}
+
+// Execute dispatches tool execution through the registry
+func (r Registry) Execute(repoRoot, toolName string, args map[string]interface{}) ToolResult {
+	for _, t := range r {
+		if t.Name == toolName {
+			return t.Run(repoRoot, Args(args))
+		}
+	}
+	return ToolResult{OK: false, Error: fmt.Sprintf("Unknown tool: %s (only %s allowed)", toolName, strings.Join(r.Names(), ", "))}
+}
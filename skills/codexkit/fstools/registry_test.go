@@ -0,0 +1,31 @@
+package fstools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEverySchemaEntryDispatches(t *testing.T) {
+	repoRoot := t.TempDir()
+
+	for _, registry := range []Registry{ReadOnlyTools(), ReadWriteTools()} {
+		for _, tool := range registry.Schema() {
+			name, _ := tool["name"].(string)
+			if name == "" {
+				t.Fatalf("schema entry without name: %v", tool)
+			}
+
+			result := registry.Execute(repoRoot, name, map[string]interface{}{})
+			if strings.HasPrefix(result.Error, "Unknown tool") {
+				t.Errorf("%s is advertised but not dispatched: %s", name, result.Error)
+			}
+		}
+	}
+}
+
+func TestUnknownToolRejected(t *testing.T) {
+	result := ReadOnlyTools().Execute(t.TempDir(), "Write", map[string]interface{}{})
+	if result.OK || !strings.HasPrefix(result.Error, "Unknown tool") {
+		t.Fatalf("expected unknown tool error, got %+v", result)
+	}
+}
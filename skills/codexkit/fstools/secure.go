@@ -1,6 +1,6 @@
-// +build !windows
+//go:build !windows
 
-package main
+package fstools
 
 import (
 	"errors"
@@ -126,8 +126,9 @@ func createParentDirs(repoRoot, relPath string) error {
 			continue
 		}
 
-		// Directory doesn't exist, create it
-		if err := unix.Mkdirat(currentFD, part, 0755); err != nil {
+		// Directory doesn't exist, create it. A concurrent call may have
+		// just created it; the Openat below still refuses a symlink.
+		if err := unix.Mkdirat(currentFD, part, 0755); err != nil && err != unix.EEXIST {
 			if needClose {
 				unix.Close(currentFD)
 			}
@@ -151,3 +152,38 @@ func createParentDirs(repoRoot, relPath string) error {
 	}
 	return nil
 }
+
+// openDirSecure opens a repo directory for the *at calls, refusing symlinks
+func openDirSecure(repoRoot, dir string) (*os.File, error) {
+	if dir == "." {
+		return os.Open(repoRoot)
+	}
+	return openSecure(repoRoot, dir, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+}
+
+// renameSecure atomically replaces newName with oldName, both in the repo
+// directory dir
+func renameSecure(repoRoot, dir, oldName, newName string) error {
+	d, err := openDirSecure(repoRoot, dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := unix.Renameat(int(d.Fd()), oldName, int(d.Fd()), newName); err != nil {
+		return err
+	}
+	// Persist the rename itself; not every filesystem syncs directories
+	d.Sync()
+	return nil
+}
+
+// removeSecure removes the file name from the repo directory dir
+func removeSecure(repoRoot, dir, name string) error {
+	d, err := openDirSecure(repoRoot, dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return unix.Unlinkat(int(d.Fd()), name, 0)
+}
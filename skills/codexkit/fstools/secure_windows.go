@@ -1,10 +1,9 @@
-// +build windows
+//go:build windows
 
-package main
+package fstools
 
 import (
 	"errors"
-	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -114,3 +113,14 @@ func createParentDirs(repoRoot, relPath string) error {
 
 	return os.MkdirAll(parentPath, 0755)
 }
+
+// renameSecure replaces newName with oldName, both in the repo directory dir
+func renameSecure(repoRoot, dir, oldName, newName string) error {
+	dirPath := filepath.Join(repoRoot, dir)
+	return os.Rename(filepath.Join(dirPath, oldName), filepath.Join(dirPath, newName))
+}
+
+// removeSecure removes the file name from the repo directory dir
+func removeSecure(repoRoot, dir, name string) error {
+	return os.Remove(filepath.Join(repoRoot, dir, name))
+}
@@ -0,0 +1,105 @@
+package fstools
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Security patterns
+	denyBasenamesRE = regexp.MustCompile(`(^\.env$|^\.env\..+|^id_rsa$|^id_rsa\..+|^known_hosts$|^config$|^credentials$|^\.npmrc$|^\.pypirc$|^\.netrc$|^secrets$|^secrets\..+)`)
+	denyExtRE       = regexp.MustCompile(`(?i)(\.pem$|\.key$|\.p12$|\.pfx$|\.cer$|\.crt$|\.der$|\.kdbx$|\.tfstate$|\.tfvars$)`)
+	denyPathRE      = regexp.MustCompile(`(^|/)\.git(/|$)|(^|/)\.codex-sessions(/|$)|\.docker/config\.json$`)
+)
+
+// IsDeniedPath checks if a path matches security denylist patterns
+func IsDeniedPath(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	base := filepath.Base(relPath)
+
+	if denyBasenamesRE.MatchString(base) {
+		return true
+	}
+	if denyExtRE.MatchString(relPath) {
+		return true
+	}
+	if denyPathRE.MatchString(relPath) {
+		return true
+	}
+	return false
+}
+
+// requireSafePath validates that a path is safe (no traversal, no absolute)
+func requireSafePath(path string) error {
+	if path == "" || strings.ContainsAny(path, "\n\r") {
+		return fmt.Errorf("invalid path")
+	}
+	// Block Windows volume-prefixed paths (C:, D:, UNC)
+	if vol := filepath.VolumeName(path); vol != "" {
+		return fmt.Errorf("volume paths not allowed")
+	}
+	if filepath.IsAbs(path) {
+		return fmt.Errorf("absolute paths not allowed")
+	}
+	if strings.HasPrefix(path, "~") {
+		return fmt.Errorf("home paths not allowed")
+	}
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for _, part := range parts {
+		if part == ".." {
+			return fmt.Errorf("parent traversal not allowed")
+		}
+	}
+	return nil
+}
+
+// confineToRepo ensures path is within repo root and returns absolute path
+func confineToRepo(repoRoot, relPath string) (string, error) {
+	if err := requireSafePath(relPath); err != nil {
+		return "", err
+	}
+
+	absRepo, err := filepath.EvalSymlinks(repoRoot)
+	if err != nil {
+		return "", fmt.Errorf("repo root resolution failed: %w", err)
+	}
+
+	targetPath := filepath.Join(repoRoot, relPath)
+	absTarget, err := filepath.EvalSymlinks(targetPath)
+	if err != nil {
+		// File might not exist yet - that's OK, just check the parent
+		parent := filepath.Dir(targetPath)
+		absParent, err2 := filepath.EvalSymlinks(parent)
+		if err2 != nil {
+			return "", fmt.Errorf("path resolution failed: %w", err)
+		}
+		// Check parent is in repo
+		if !strings.HasPrefix(absParent, absRepo+string(filepath.Separator)) && absParent != absRepo {
+			return "", fmt.Errorf("path escapes repo root")
+		}
+		return targetPath, nil
+	}
+
+	// Check resolved path is within repo
+	if !strings.HasPrefix(absTarget, absRepo+string(filepath.Separator)) && absTarget != absRepo {
+		return "", fmt.Errorf("path escapes repo root")
+	}
+
+	return absTarget, nil
+}
+
+// isSymlink checks if path is a symlink (using lstat, not stat)
+func isSymlink(path string) (bool, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return info.Mode()&fs.ModeSymlink != 0, nil
+}
@@ -0,0 +1,484 @@
+package fstools
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxResults   = 200
+	DefaultMaxReadLines = 400
+	maxGrepFileSize     = 2 * 1024 * 1024 // 2MB
+)
+
+// ToolResult represents the result of a tool execution
+type ToolResult struct {
+	OK      bool                   `json:"ok"`
+	Tool    string                 `json:"tool,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+	Results interface{}            `json:"results,omitempty"`
+	Content string                 `json:"content,omitempty"`
+	Count   int                    `json:"count,omitempty"`
+	Path    string                 `json:"path,omitempty"`
+	Extra   map[string]interface{} `json:",inline"`
+
+	// Truncated is set when the result was cut to fit its size budget
+	Truncated *Truncation `json:"truncated,omitempty"`
+}
+
+// Glob sort orders
+const (
+	SortByPath  = "path"  // Lexical, the default
+	SortByMtime = "mtime" // Most recently modified first
+)
+
+// Glob finds files matching a pattern (see globPattern for the syntax).
+// Ignored files (see walkRepo) are left out unless includeIgnored is set.
+func Glob(repoRoot, pattern string, maxResults int, sortBy string, includeIgnored bool) ToolResult {
+	if err := requireSafePath(pattern); err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Glob: %v", err)}
+	}
+
+	if maxResults <= 0 || maxResults > DefaultMaxResults {
+		maxResults = DefaultMaxResults
+	}
+	if sortBy == "" {
+		sortBy = SortByPath
+	}
+	if sortBy != SortByPath && sortBy != SortByMtime {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Glob: unknown sort %q (use path or mtime)", sortBy)}
+	}
+
+	g, err := compileGlob(pattern)
+	if err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Glob: %v", err)}
+	}
+
+	type match struct {
+		path    string
+		modTime time.Time
+	}
+	var matches []match
+	walkRepo(repoRoot, g, includeIgnored, func(relPath string, d fs.DirEntry) error {
+		if IsDeniedPath(relPath) {
+			return nil
+		}
+
+		// Only regular files; a symlink counts if its target is one inside the repo
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			if _, err := confineToRepo(repoRoot, relPath); err != nil {
+				return nil
+			}
+			if info, err = os.Stat(filepath.Join(repoRoot, filepath.FromSlash(relPath))); err != nil {
+				return nil
+			}
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		matches = append(matches, match{relPath, info.ModTime()})
+		return nil
+	})
+
+	// WalkDir visits in lexical order; mtime order breaks ties by path
+	if sortBy == SortByMtime {
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].modTime.After(matches[j].modTime)
+		})
+	}
+
+	results := []string{}
+	for _, m := range matches[:min(len(matches), maxResults)] {
+		results = append(results, m.path)
+	}
+
+	return ToolResult{
+		OK:      true,
+		Tool:    "Glob",
+		Results: results,
+		Count:   len(results),
+		Extra: map[string]interface{}{
+			"repo_root": repoRoot,
+			"pattern":   pattern,
+			"sort":      sortBy,
+			"total":     len(matches),
+		},
+	}
+}
+
+// Read reads a file with line range
+func Read(repoRoot, path string, startLine, endLine, maxLines int) ToolResult {
+	if err := requireSafePath(path); err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Read: %v", err)}
+	}
+
+	if IsDeniedPath(path) {
+		return ToolResult{OK: false, Error: "Read: access denied"}
+	}
+
+	// SECURITY: Use openat-based secure open (perfect on Unix, strict validation on Windows)
+	file, err := openSecure(repoRoot, path, os.O_RDONLY, 0)
+	if err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Read: %v", err)}
+	}
+	defer file.Close()
+
+	// Verify it's a regular file
+	info, err := file.Stat()
+	if err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Read: %v", err)}
+	}
+	if !info.Mode().IsRegular() {
+		return ToolResult{OK: false, Error: "Read: not a regular file"}
+	}
+
+	// Read lines
+	if maxLines <= 0 || maxLines > DefaultMaxReadLines {
+		maxLines = DefaultMaxReadLines
+	}
+	if startLine < 1 {
+		startLine = 1
+	}
+	if endLine <= 0 {
+		endLine = startLine + maxLines - 1
+	}
+	if endLine < startLine {
+		endLine = startLine
+	}
+	if endLine-startLine+1 > maxLines {
+		endLine = startLine + maxLines - 1
+	}
+
+	// Refuse binary files; decode other encodings to UTF-8
+	text, enc, head, binary, err := textReader(file)
+	if err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Read: %v", err)}
+	}
+	if binary {
+		kind := mimeType(path, head)
+		return ToolResult{
+			OK:    false,
+			Tool:  "Read",
+			Path:  path,
+			Error: fmt.Sprintf("Read: binary file (%d bytes, %s)", info.Size(), kind),
+			Extra: map[string]interface{}{"size": info.Size(), "mime": kind},
+		}
+	}
+
+	scanner := bufio.NewScanner(text)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // 1MB line limit
+	lines := []string{}
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		if lineNum < startLine {
+			continue
+		}
+		if lineNum > endLine {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%06d\t%s", lineNum, scanner.Text()))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Read: %v", err)}
+	}
+
+	return ToolResult{
+		OK:      true,
+		Tool:    "Read",
+		Path:    path,
+		Content: strings.Join(lines, "\n"),
+		Extra:   withEncoding(map[string]interface{}{"start": startLine, "end": endLine, "repo_root": repoRoot}, enc),
+	}
+}
+
+// withEncoding notes a file's encoding in a result unless it is plain UTF-8
+func withEncoding(extra map[string]interface{}, enc textEncoding) map[string]interface{} {
+	if !enc.plain() {
+		extra["encoding"] = enc.String()
+	}
+	return extra
+}
+
+// existingEncoding returns the encoding of the text file at path so a
+// rewrite can keep it; new and empty files are plain UTF-8
+func existingEncoding(repoRoot, path string) (textEncoding, error) {
+	file, err := openSecure(repoRoot, path, os.O_RDONLY, 0)
+	if err != nil {
+		return textEncoding{Name: EncodingUTF8}, nil
+	}
+	defer file.Close()
+	head := make([]byte, sniffBytes)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return textEncoding{}, err
+	}
+	enc, binary := sniffEncoding(head[:n])
+	if binary {
+		return textEncoding{Name: EncodingUTF8}, nil // Replaced wholesale
+	}
+	return enc, nil
+}
+
+// Write creates or overwrites a file
+func Write(repoRoot, path, content string) ToolResult {
+	if err := requireSafePath(path); err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Write: %v", err)}
+	}
+
+	if IsDeniedPath(path) {
+		return ToolResult{OK: false, Error: "Write: access denied"}
+	}
+
+	// SECURITY: Create parent directories with symlink protection
+	if err := createParentDirs(repoRoot, path); err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Write: mkdir failed: %v", err)}
+	}
+
+	// Overwriting keeps a Unicode encoding and BOM. Latin-1 is only a guess
+	// for files that are not UTF-8 (they may be CP949 or Shift-JIS), so new
+	// content replaces those as UTF-8; Edit and MultiEdit keep their bytes.
+	enc, err := existingEncoding(repoRoot, path)
+	if err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Write: %v", err)}
+	}
+	if enc.Name == EncodingLatin1 {
+		enc = textEncoding{Name: EncodingUTF8}
+	}
+	data, err := encodeText(content, enc)
+	if err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Write: %v", err)}
+	}
+
+	// SECURITY: Open with complete protection (openat on Unix, strict validation on Windows)
+	file, err := openSecure(repoRoot, path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Write: %v", err)}
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Write: %v", err)}
+	}
+
+	return ToolResult{
+		OK:    true,
+		Tool:  "Write",
+		Path:  path,
+		Extra: withEncoding(map[string]interface{}{"bytes": len(data)}, enc),
+	}
+}
+
+// Edit performs precise string replacement
+func Edit(repoRoot, path, oldString, newString string) ToolResult {
+	if err := requireSafePath(path); err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Edit: %v", err)}
+	}
+
+	if IsDeniedPath(path) {
+		return ToolResult{OK: false, Error: "Edit: access denied"}
+	}
+
+	if oldString == "" {
+		return ToolResult{OK: false, Error: "Edit: old_string must be non-empty"}
+	}
+
+	contentStr, enc, err := readEditable(repoRoot, path)
+	if err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Edit: %v", err)}
+	}
+	if !strings.Contains(contentStr, oldString) {
+		return ToolResult{OK: false, Error: "Edit: old_string not found in file"}
+	}
+
+	count := strings.Count(contentStr, oldString)
+	if count > 1 {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Edit: old_string appears %d times (must be unique)", count)}
+	}
+
+	if err := writeEdited(repoRoot, path, strings.Replace(contentStr, oldString, newString, 1), enc); err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("Edit: %v", err)}
+	}
+
+	return ToolResult{
+		OK:    true,
+		Tool:  "Edit",
+		Path:  path,
+		Extra: withEncoding(map[string]interface{}{"replaced": len(oldString), "with": len(newString)}, enc),
+	}
+}
+
+// EditOp is one replacement in a MultiEdit
+type EditOp struct {
+	OldString  string `json:"old_string"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all,omitempty"` // Replace every occurrence instead of requiring one
+}
+
+// MultiEdit applies edits to one file in order, each to the result of the
+// previous ones. Every edit is checked in memory first; the file is
+// written only if all of them apply, so a failing edit changes nothing.
+func MultiEdit(repoRoot, path string, edits []EditOp) ToolResult {
+	if err := requireSafePath(path); err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("MultiEdit: %v", err)}
+	}
+
+	if IsDeniedPath(path) {
+		return ToolResult{OK: false, Error: "MultiEdit: access denied"}
+	}
+
+	if len(edits) == 0 {
+		return ToolResult{OK: false, Error: "MultiEdit: edits must be a non-empty list"}
+	}
+
+	content, enc, err := readEditable(repoRoot, path)
+	if err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("MultiEdit: %v", err)}
+	}
+
+	replacements := 0
+	for i, edit := range edits {
+		fail := func(format string, args ...interface{}) ToolResult {
+			return ToolResult{
+				OK:    false,
+				Tool:  "MultiEdit",
+				Path:  path,
+				Error: fmt.Sprintf("MultiEdit: edit %d: %s; no edits were applied", i+1, fmt.Sprintf(format, args...)),
+				Extra: map[string]interface{}{"failed_edit": i + 1},
+			}
+		}
+		if edit.OldString == "" {
+			return fail("old_string must be non-empty")
+		}
+		if edit.OldString == edit.NewString {
+			return fail("old_string and new_string are identical")
+		}
+
+		count := strings.Count(content, edit.OldString)
+		switch {
+		case count == 0 && i > 0:
+			return fail("old_string not found after applying the previous edits")
+		case count == 0:
+			return fail("old_string not found in file")
+		case count > 1 && !edit.ReplaceAll:
+			return fail("old_string appears %d times (must be unique, or set replace_all)", count)
+		}
+		content = strings.ReplaceAll(content, edit.OldString, edit.NewString)
+		replacements += count
+	}
+
+	if err := writeEdited(repoRoot, path, content, enc); err != nil {
+		return ToolResult{OK: false, Error: fmt.Sprintf("MultiEdit: %v", err)}
+	}
+
+	return ToolResult{
+		OK:    true,
+		Tool:  "MultiEdit",
+		Path:  path,
+		Extra: withEncoding(map[string]interface{}{"edits": len(edits), "replacements": replacements}, enc),
+	}
+}
+
+// readEditable reads a regular text file for editing, decoded to UTF-8
+func readEditable(repoRoot, path string) (string, textEncoding, error) {
+	// SECURITY: Read with complete protection
+	file, err := openSecure(repoRoot, path, os.O_RDONLY, 0)
+	if err != nil {
+		return "", textEncoding{}, err
+	}
+	defer file.Close()
+
+	// Verify regular file
+	info, err := file.Stat()
+	if err != nil {
+		return "", textEncoding{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return "", textEncoding{}, fmt.Errorf("not a regular file")
+	}
+
+	// Read from already-opened FD (no path reopen)
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return "", textEncoding{}, fmt.Errorf("read failed: %v", err)
+	}
+
+	// Edits work on the decoded text and are written back in the same encoding
+	enc, binary := sniffEncoding(content[:min(len(content), sniffBytes)])
+	if binary {
+		return "", textEncoding{}, fmt.Errorf("binary file")
+	}
+	return decodeText(content, enc), enc, nil
+}
+
+// writeEdited replaces the content of an existing file, encoded as enc. The
+// content goes to a temporary file beside it that is synced and renamed over
+// the file, so a failed write leaves the original intact.
+func writeEdited(repoRoot, path, content string, enc textEncoding) error {
+	data, err := encodeText(content, enc)
+	if err != nil {
+		return err
+	}
+
+	// The replacement keeps the original's permissions
+	orig, err := openSecure(repoRoot, path, os.O_RDONLY, 0)
+	if err != nil {
+		return fmt.Errorf("open for write failed: %v", err)
+	}
+	info, err := orig.Stat()
+	orig.Close()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("not a regular file")
+	}
+
+	// SECURITY: Create the temporary file with complete protection
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	tmpName := fmt.Sprintf(".%s.%d.tmp", name, rand.Uint64())
+	tmp, err := openSecure(repoRoot, filepath.Join(dir, tmpName), os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("create temp file failed: %v", err)
+	}
+	err = writeSynced(tmp, data, info.Mode().Perm())
+	if err == nil {
+		err = renameSecure(repoRoot, dir, tmpName, name)
+	}
+	if err != nil {
+		removeSecure(repoRoot, dir, tmpName)
+		return fmt.Errorf("write failed: %v", err)
+	}
+	return nil
+}
+
+// writeSynced writes data to a new file, sets its mode (the umask may have
+// narrowed it) and flushes it to disk before closing it
+func writeSynced(file *os.File, data []byte, perm os.FileMode) error {
+	_, err := file.Write(data)
+	if err == nil {
+		err = file.Chmod(perm)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package fstools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMultiEdit(t *testing.T) {
+	repoRoot := t.TempDir()
+	file := filepath.Join(repoRoot, "app.go")
+	original := "package app\n\nfunc Old() {}\n\nvar a = Old\nvar b = Old\n"
+	os.WriteFile(file, []byte(original), 0644)
+
+	run := func(args map[string]interface{}) ToolResult {
+		return ReadWriteTools().Execute(repoRoot, "MultiEdit", args)
+	}
+	edits := func(e ...map[string]interface{}) []interface{} {
+		out := make([]interface{}, len(e))
+		for i := range e {
+			out[i] = e[i]
+		}
+		return out
+	}
+
+	// A failing edit leaves the file untouched, even after earlier edits matched
+	failures := []struct {
+		edits []interface{}
+		want  string
+	}{
+		{edits(map[string]interface{}{"old_string": "package app", "new_string": "package core"}, map[string]interface{}{"old_string": "Old", "new_string": "New"}),
+			"edit 2: old_string appears 3 times (must be unique, or set replace_all)"},
+		{edits(map[string]interface{}{"old_string": "func Old", "new_string": "func New"}, map[string]interface{}{"old_string": "func Old", "new_string": "func Newer"}),
+			"edit 2: old_string not found after applying the previous edits"},
+		{edits(map[string]interface{}{"old_string": "", "new_string": "x"}), "edit 1: old_string must be non-empty"},
+		{nil, "edits must be a non-empty list"},
+	}
+	for _, tt := range failures {
+		r := run(map[string]interface{}{"path": "app.go", "edits": tt.edits})
+		if r.OK || !strings.Contains(r.Error, tt.want) {
+			t.Errorf("edits %v: got %+v, want error %q", tt.edits, r, tt.want)
+		}
+		if data, _ := os.ReadFile(file); string(data) != original {
+			t.Fatalf("failed MultiEdit modified the file: %q", data)
+		}
+	}
+	if r := run(map[string]interface{}{"path": "app.go", "edits": "not a list"}); r.OK || !strings.Contains(r.Error, "invalid edits") {
+		t.Errorf("malformed edits = %+v", r)
+	}
+
+	r := run(map[string]interface{}{"path": "app.go", "edits": edits(
+		map[string]interface{}{"old_string": "package app", "new_string": "package core"},
+		map[string]interface{}{"old_string": "Old", "new_string": "New", "replace_all": true},
+		map[string]interface{}{"old_string": "var b = New\n", "new_string": ""},
+	)})
+	if !r.OK || r.Extra["edits"] != 3 || r.Extra["replacements"] != 5 {
+		t.Fatalf("MultiEdit = %+v", r)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "package core\n\nfunc New() {}\n\nvar a = New\n" {
+		t.Errorf("file after MultiEdit = %q", data)
+	}
+
+	if r := run(map[string]interface{}{"path": ".env", "edits": edits(map[string]interface{}{"old_string": "a", "new_string": "b"})}); r.OK || r.Error != "MultiEdit: access denied" {
+		t.Errorf("denied path = %+v", r)
+	}
+}
+
+func TestEditReplacesFileKeepingMode(t *testing.T) {
+	repoRoot := t.TempDir()
+	for _, path := range []string{"run.sh", "scripts/run.sh"} {
+		file := filepath.Join(repoRoot, path)
+		os.MkdirAll(filepath.Dir(file), 0755)
+		os.WriteFile(file, []byte("echo old\n"), 0644)
+		os.Chmod(file, 0750)
+
+		if r := Edit(repoRoot, path, "old", "new"); !r.OK {
+			t.Fatalf("Edit %s = %+v", path, r)
+		}
+		if data, _ := os.ReadFile(file); string(data) != "echo new\n" {
+			t.Errorf("%s after Edit = %q", path, data)
+		}
+		if info, err := os.Stat(file); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0750) {
+			t.Errorf("%s mode after Edit = %v, %v; want 0750", path, info.Mode(), err)
+		}
+		// The temporary file was renamed over the original
+		entries, _ := os.ReadDir(filepath.Dir(file))
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), ".tmp") {
+				t.Errorf("%s: temporary file %s left behind", path, e.Name())
+			}
+		}
+	}
+}
+
+func TestWriteConcurrentlyIntoNewDirectory(t *testing.T) {
+	repoRoot := t.TempDir()
+	for round := 0; round < 20; round++ {
+		var wg sync.WaitGroup
+		errs := make(chan string, 16)
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				path := fmt.Sprintf("pkg%d/sub/file%d.go", round, i)
+				if r := Write(repoRoot, path, "package sub\n"); !r.OK {
+					errs <- r.Error
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Errorf("round %d: %s", round, err)
+		}
+	}
+}
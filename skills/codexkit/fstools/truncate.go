@@ -0,0 +1,176 @@
+package fstools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DefaultResultBudget = 64 * 1024 // Max bytes of one tool result's JSON sent to the model
+	maxResultLineBytes  = 500       // Per-line cap for matches and file paths in an oversized result
+	maxContentLineBytes = 2000      // Per-line cap for Read content in an oversized result
+	truncationReserve   = 512       // Room kept for the truncation note itself
+)
+
+// Truncation describes what was cut from a result that exceeded its budget
+type Truncation struct {
+	LinesClipped int    `json:"lines_clipped,omitempty"` // Lines shortened to the per-line cap
+	Omitted      int    `json:"omitted,omitempty"`       // Results or lines dropped from the end
+	Note         string `json:"note"`
+	Hint         string `json:"hint,omitempty"` // How to ask for less
+}
+
+// Truncate fits r into budget bytes of JSON. Long lines are clipped first;
+// if that is not enough, trailing results (or content lines) are dropped.
+// Results within budget, or a budget <= 0, are returned unchanged.
+func (r ToolResult) Truncate(budget int) ToolResult {
+	if budget <= 0 || jsonSize(r) <= budget {
+		return r
+	}
+	t := &Truncation{}
+
+	results, isList := r.Results.([]string)
+	if isList {
+		results = clipLines(results, maxResultLineBytes, t)
+	}
+	var lines []string
+	if r.Content != "" {
+		lines = clipLines(strings.Split(r.Content, "\n"), maxContentLineBytes, t)
+	}
+
+	// Whatever is not a list or content (error, extra fields) is kept whole
+	base := r
+	base.Results, base.Content, base.Truncated = nil, "", t
+	room := budget - jsonSize(base) - truncationReserve
+	if isList {
+		keep := fitLines(results, room)
+		room -= joinedSize(results[:keep])
+		t.Omitted += len(results) - keep
+		results = results[:keep]
+	}
+	if lines != nil {
+		keep := fitLines(lines, room)
+		t.Omitted += len(lines) - keep
+		lines = lines[:keep]
+	}
+
+	out := r
+	if isList {
+		out.Results = results
+	}
+	if lines != nil {
+		out.Content = strings.Join(lines, "\n")
+		// Read reports its range; shrink it to what is actually returned
+		if end, ok := out.Extra["end"]; ok && len(lines) > 0 {
+			if n, ok := lineNumber(lines[len(lines)-1]); ok && n != end {
+				out.Extra = copyExtra(out.Extra)
+				out.Extra["end"] = n
+			}
+		}
+	}
+	out.Truncated = t
+	t.Note, t.Hint = truncationNote(out, t, lines)
+	return out
+}
+
+// truncationNote explains the cut in terms of the tool that was called
+func truncationNote(r ToolResult, t *Truncation, lines []string) (note, hint string) {
+	var parts []string
+	if t.Omitted > 0 {
+		unit := "results"
+		switch r.Tool {
+		case "Grep":
+			unit = "matches"
+		case "Glob":
+			unit = "files"
+		case "Read":
+			unit = "lines"
+		}
+		parts = append(parts, fmt.Sprintf("%d more %s omitted", t.Omitted, unit))
+	}
+	if t.LinesClipped > 0 {
+		parts = append(parts, fmt.Sprintf("%d long lines clipped", t.LinesClipped))
+	}
+	note = strings.Join(parts, "; ") + " to fit the result size limit"
+
+	switch r.Tool {
+	case "Grep":
+		hint = "Narrow the search: a more specific query, a glob such as src/**/*.go, output_mode files_with_matches, or a smaller max_results"
+	case "Glob":
+		hint = "Use a more specific pattern, e.g. a subdirectory or file extension"
+	case "Read":
+		hint = "Read a smaller range with start_line/end_line"
+		if t.Omitted > 0 && len(lines) > 0 {
+			if n, ok := lineNumber(lines[len(lines)-1]); ok {
+				hint += fmt.Sprintf("; continue with start_line=%d", n+1)
+			}
+		}
+	}
+	return note, hint
+}
+
+// clipLines caps each line at limit bytes, counting clipped lines in t
+func clipLines(lines []string, limit int, t *Truncation) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) <= limit {
+			out[i] = line
+			continue
+		}
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		out[i] = fmt.Sprintf("%s... [%d bytes clipped]", line[:cut], len(line)-cut)
+		t.LinesClipped++
+	}
+	return out
+}
+
+// fitLines returns how many leading lines fit in room bytes of JSON
+func fitLines(lines []string, room int) int {
+	used := 0
+	for i, line := range lines {
+		used += jsonSize(line) + 1
+		if used > room {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+// joinedSize is the JSON size of lines as a list
+func joinedSize(lines []string) int {
+	size := 0
+	for _, line := range lines {
+		size += jsonSize(line) + 1
+	}
+	return size
+}
+
+// lineNumber parses the line number prefix Read puts on each line
+func lineNumber(line string) (int, bool) {
+	prefix, _, ok := strings.Cut(line, "\t")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(prefix)
+	return n, err == nil
+}
+
+// copyExtra returns a shallow copy of a result's extra fields
+func copyExtra(extra map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(extra))
+	for k, v := range extra {
+		out[k] = v
+	}
+	return out
+}
+
+func jsonSize(v interface{}) int {
+	data, _ := json.Marshal(v)
+	return len(data)
+}
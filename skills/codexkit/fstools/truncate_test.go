@@ -0,0 +1,67 @@
+package fstools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTruncateGrepResults(t *testing.T) {
+	repoRoot := t.TempDir()
+	minified := strings.Repeat("x", 5000)
+	var src strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&src, "needle %d %s\n", i, minified)
+	}
+	os.WriteFile(filepath.Join(repoRoot, "app.min.js"), []byte(src.String()), 0644)
+
+	result := Grep(repoRoot, GrepOptions{Query: "needle"}).Truncate(16 * 1024)
+	data, _ := json.Marshal(result)
+	if len(data) > 16*1024 {
+		t.Fatalf("truncated result is %d bytes", len(data))
+	}
+	tr := result.Truncated
+	if tr == nil || tr.LinesClipped == 0 || tr.Omitted == 0 || result.Count != 200 {
+		t.Fatalf("truncation = %+v, count = %d", tr, result.Count)
+	}
+	kept := result.Results.([]string)
+	if len(kept)+tr.Omitted != 200 || !strings.Contains(kept[0], "bytes clipped]") {
+		t.Errorf("kept %d matches, first = %.80q", len(kept), kept[0])
+	}
+	if !strings.Contains(tr.Note, fmt.Sprintf("%d more matches omitted", tr.Omitted)) || !strings.Contains(tr.Hint, "glob") {
+		t.Errorf("note = %q, hint = %q", tr.Note, tr.Hint)
+	}
+}
+
+func TestTruncateReadContent(t *testing.T) {
+	repoRoot := t.TempDir()
+	var src strings.Builder
+	for i := 1; i <= 400; i++ {
+		fmt.Fprintf(&src, "line %d %s\n", i, strings.Repeat("y", 300))
+	}
+	os.WriteFile(filepath.Join(repoRoot, "big.txt"), []byte(src.String()), 0644)
+
+	result := Read(repoRoot, "big.txt", 1, 0, 0).Truncate(32 * 1024)
+	tr := result.Truncated
+	if tr == nil || tr.LinesClipped != 0 || tr.Omitted == 0 {
+		t.Fatalf("truncation = %+v", tr)
+	}
+	lines := strings.Split(result.Content, "\n")
+	last, _ := lineNumber(lines[len(lines)-1])
+	if last != len(lines) || result.Extra["end"] != last || last+tr.Omitted != 400 {
+		t.Errorf("kept lines 1-%d, end = %v, omitted %d", last, result.Extra["end"], tr.Omitted)
+	}
+	if !strings.Contains(tr.Hint, fmt.Sprintf("start_line=%d", last+1)) {
+		t.Errorf("hint = %q", tr.Hint)
+	}
+}
+
+func TestTruncateWithinBudget(t *testing.T) {
+	r := ToolResult{OK: true, Tool: "Glob", Results: []string{"a.go", "b.go"}, Count: 2}
+	if got := r.Truncate(DefaultResultBudget); got.Truncated != nil || len(got.Results.([]string)) != 2 {
+		t.Errorf("small result changed: %+v", got)
+	}
+}
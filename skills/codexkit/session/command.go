@@ -0,0 +1,530 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"codexkit/api"
+)
+
+// Command implements the `sessions list|show|rm|prune|fork` and
+// `transcript show` subcommands
+type Command struct {
+	Prog    string                       // Binary name for usage messages
+	Dir     string                       // Sessions directory
+	Connect func() (api.Provider, error) // Called only when a command needs the API
+
+	// SystemPrompt rebuilds a session's system prompt when its transcript
+	// predates recording it (used by fork)
+	SystemPrompt func(name string, d Data) string
+	Stdout       io.Writer
+	Stderr       io.Writer
+}
+
+// Run executes the subcommand in args (starting with "sessions" or
+// "transcript") and returns the process exit code
+func (c Command) Run(ctx context.Context, args []string) int {
+	if len(args) < 2 {
+		c.usage()
+		return 2
+	}
+
+	if args[0] == "transcript" {
+		if args[1] != "show" {
+			fmt.Fprintf(c.Stderr, "Unknown transcript command: %s\n", args[1])
+			c.usage()
+			return 2
+		}
+		return c.showTranscript(args[2:])
+	}
+
+	args = args[1:]
+	switch args[0] {
+	case "list", "ls":
+		return c.list(args[1:])
+	case "show":
+		return c.show(args[1:])
+	case "rm", "delete":
+		return c.remove(ctx, args[1:])
+	case "prune":
+		return c.prune(ctx, args[1:])
+	case "fork":
+		return c.fork(ctx, args[1:])
+	}
+	fmt.Fprintf(c.Stderr, "Unknown sessions command: %s\n", args[0])
+	c.usage()
+	return 2
+}
+
+func (c Command) usage() {
+	fmt.Fprintf(c.Stderr, `Usage:
+  %[1]s sessions list [--json]
+  %[1]s sessions show <name> [--json]
+  %[1]s sessions rm [--remote] <name>...
+  %[1]s sessions prune --older-than <age> [--remote] [--dry-run]
+  %[1]s sessions fork <name> <new-name> [--from-response]
+  %[1]s transcript show <name> [--run N] [--json]
+
+<age> is a duration like 12h or 30m, or days like 7d.
+--remote also deletes the conversation stored by the API.
+fork replays the local transcript into a new conversation; --from-response
+starts a chain from the last response (previous_response_id) instead.
+`, c.Prog)
+}
+
+func (c Command) list(args []string) int {
+	fs := c.flags("list")
+	asJSON := fs.Bool("json", false, "print JSON")
+	rest, err := parseFlags(fs, args)
+	if err != nil || len(rest) != 0 {
+		return 2
+	}
+
+	entries, err := List(c.Dir)
+	if err != nil {
+		fmt.Fprintf(c.Stderr, "Failed to list sessions: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		out := make([]entryJSON, 0, len(entries))
+		for _, e := range entries {
+			out = append(out, newEntryJSON(e))
+		}
+		return c.writeJSON(out)
+	}
+
+	if len(entries) == 0 {
+		fmt.Fprintf(c.Stdout, "No sessions in %s\n", c.Dir)
+		return 0
+	}
+	tw := tabwriter.NewWriter(c.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATUS\tRUNS\tITERS\tTOKENS\tCOST\tUPDATED\tPROMPT")
+	for _, e := range entries {
+		if e.Err != nil {
+			fmt.Fprintf(tw, "%s\tunreadable\t-\t-\t-\t-\t%s\t%v\n", e.Name, formatTime(e.Updated()), e.Err)
+			continue
+		}
+		d := e.Data
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t$%.4f\t%s\t%s\n",
+			e.Name, orDash(d.Status), d.Runs, d.Iterations, d.Usage.Total(), d.CostUSD,
+			formatTime(e.Updated()), truncate(d.Prompt, 40))
+	}
+	tw.Flush()
+	return 0
+}
+
+func (c Command) show(args []string) int {
+	fs := c.flags("show")
+	asJSON := fs.Bool("json", false, "print JSON")
+	rest, err := parseFlags(fs, args)
+	if err != nil || len(rest) != 1 {
+		c.usage()
+		return 2
+	}
+	e, ok := c.find(rest[0])
+	if !ok {
+		return 1
+	}
+
+	if *asJSON {
+		return c.writeJSON(newEntryJSON(e))
+	}
+
+	d := e.Data
+	tw := tabwriter.NewWriter(c.Stdout, 0, 0, 2, ' ', 0)
+	row := func(k string, v interface{}) { fmt.Fprintf(tw, "%s:\t%v\n", k, v) }
+	row("Name", e.Name)
+	row("File", e.File)
+	row("Kind", orDash(d.Kind))
+	row("Status", orDash(d.Status))
+	if d.Error != "" {
+		row("Error", d.Error)
+	}
+	row("Conversation", orDash(d.ConversationID))
+	if len(d.ConversationState) > 0 {
+		row("Client-side state", fmt.Sprintf("%d bytes", len(d.ConversationState)))
+	}
+	if d.LastResponseID != "" {
+		row("Last response", d.LastResponseID)
+	}
+	if d.ForkedFrom != "" {
+		row("Forked from", d.ForkedFrom)
+	}
+	row("Created", formatTime(d.CreatedAt))
+	row("Updated", formatTime(e.Updated()))
+	row("Model", orDash(strings.TrimSpace(d.Model+" "+d.ReasoningEffort)))
+	row("Repo root", orDash(d.RepoRoot))
+	if d.PlanFile != "" {
+		row("Plan", fmt.Sprintf("%s (sha256 %s)", d.PlanFile, d.PlanSHA256[:min(12, len(d.PlanSHA256))]))
+	}
+	row("Runs", fmt.Sprintf("%d (%d iterations, %d in last run)", d.Runs, d.Iterations, d.LastIterations))
+	row("Tokens", fmt.Sprintf("%d input (%d cached), %d output (%d reasoning)",
+		d.Usage.InputTokens, d.Usage.CachedTokens, d.Usage.OutputTokens, d.Usage.ReasoningTokens))
+	row("Cost", fmt.Sprintf("est. $%.4f", d.CostUSD))
+	if len(d.PendingOutputs) > 0 {
+		row("Pending outputs", len(d.PendingOutputs))
+	}
+	row("Prompt", orDash(d.Prompt))
+	tw.Flush()
+
+	if len(d.FilesModified) > 0 {
+		fmt.Fprintln(c.Stdout, "Files modified:")
+		for _, path := range d.FilesModified {
+			fmt.Fprintf(c.Stdout, "  - %s\n", path)
+		}
+	}
+	return 0
+}
+
+func (c Command) remove(ctx context.Context, args []string) int {
+	fs := c.flags("rm")
+	remote := fs.Bool("remote", false, "also delete the remote conversation")
+	names, err := parseFlags(fs, args)
+	if err != nil || len(names) == 0 {
+		c.usage()
+		return 2
+	}
+
+	var entries []Entry
+	for _, name := range names {
+		e, ok := c.find(name)
+		if !ok {
+			return 1
+		}
+		entries = append(entries, e)
+	}
+	return c.delete(ctx, entries, *remote)
+}
+
+func (c Command) prune(ctx context.Context, args []string) int {
+	fs := c.flags("prune")
+	olderThan := fs.String("older-than", "", "minimum age of sessions to remove (e.g. 7d, 12h)")
+	remote := fs.Bool("remote", false, "also delete the remote conversations")
+	dryRun := fs.Bool("dry-run", false, "only print what would be removed")
+	rest, err := parseFlags(fs, args)
+	if err != nil || len(rest) != 0 || *olderThan == "" {
+		c.usage()
+		return 2
+	}
+	age, err := ParseAge(*olderThan)
+	if err != nil {
+		fmt.Fprintln(c.Stderr, err)
+		return 2
+	}
+
+	entries, err := List(c.Dir)
+	if err != nil {
+		fmt.Fprintf(c.Stderr, "Failed to list sessions: %v\n", err)
+		return 1
+	}
+	cutoff := time.Now().Add(-age)
+	var stale []Entry
+	for _, e := range entries {
+		if e.Updated().Before(cutoff) {
+			stale = append(stale, e)
+		}
+	}
+
+	if len(stale) == 0 {
+		fmt.Fprintf(c.Stdout, "No sessions older than %s\n", *olderThan)
+	}
+	if *dryRun {
+		for _, e := range stale {
+			fmt.Fprintf(c.Stdout, "Would remove %s (updated %s)\n", e.Name, formatTime(e.Updated()))
+		}
+		orphans, _ := OrphanLocks(c.Dir)
+		for _, name := range orphans {
+			fmt.Fprintf(c.Stdout, "Would remove lock file of %s\n", name)
+		}
+		return 0
+	}
+
+	code := 0
+	if len(stale) > 0 {
+		code = c.delete(ctx, stale, *remote)
+	}
+	return max(code, c.removeLocks(ctx))
+}
+
+// removeLocks deletes the lock files of sessions that no longer exist,
+// keeping those a run holds
+func (c Command) removeLocks(ctx context.Context) int {
+	orphans, err := OrphanLocks(c.Dir)
+	if err != nil {
+		fmt.Fprintf(c.Stderr, "Failed to list lock files: %v\n", err)
+		return 1
+	}
+	code := 0
+	for _, name := range orphans {
+		err := RemoveLock(ctx, c.Dir, name)
+		switch {
+		case err == nil:
+			fmt.Fprintf(c.Stdout, "Removed lock file of %s\n", name)
+		case !errors.Is(err, ErrLocked):
+			fmt.Fprintf(c.Stderr, "Failed to remove lock file of %s: %v\n", name, err)
+			code = 1
+		}
+	}
+	return code
+}
+
+func (c Command) showTranscript(args []string) int {
+	fs := c.flags("transcript show")
+	asJSON := fs.Bool("json", false, "print raw JSONL events")
+	runFilter := fs.Int("run", 0, "only show this run (default all)")
+	rest, err := parseFlags(fs, args)
+	if err != nil || len(rest) != 1 {
+		c.usage()
+		return 2
+	}
+	name := rest[0]
+	if !ValidName(name) {
+		fmt.Fprintf(c.Stderr, "Invalid session name: %s\n", name)
+		return 2
+	}
+
+	events, err := ReadTranscript(TranscriptFile(c.Dir, name))
+	if os.IsNotExist(err) {
+		fmt.Fprintf(c.Stderr, "%s: no transcript in %s\n", name, c.Dir)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(c.Stderr, err)
+		if len(events) == 0 {
+			return 1
+		}
+	}
+
+	enc := json.NewEncoder(c.Stdout)
+	for _, ev := range events {
+		if *runFilter != 0 && ev.Run != *runFilter {
+			continue
+		}
+		if *asJSON {
+			enc.Encode(ev)
+			continue
+		}
+		writeEvent(c.Stdout, ev)
+	}
+	return 0
+}
+
+// delete removes session files, deleting remote conversations first when
+// asked; a session whose remote delete fails is kept so it can be retried
+func (c Command) delete(ctx context.Context, entries []Entry, remote bool) int {
+	var deleter api.Deleter
+	if remote {
+		provider, err := c.Connect()
+		if err != nil {
+			fmt.Fprintln(c.Stderr, err)
+			return 2
+		}
+		if d, ok := provider.(api.Deleter); ok {
+			deleter = d
+		} else {
+			fmt.Fprintln(c.Stderr, "Provider keeps conversations client-side; nothing to delete remotely")
+		}
+	}
+
+	code := 0
+	for _, e := range entries {
+		lock, err := Lock(ctx, c.Dir, e.Name, 0)
+		if err != nil {
+			fmt.Fprintf(c.Stderr, "Skipping %s: %v\n", e.Name, err)
+			code = 1
+			continue
+		}
+		// The lock file stays: another process may already hold or wait
+		// on it. Prune removes it once unused (see RemoveLock).
+		if !c.deleteOne(ctx, e, deleter) {
+			code = 1
+		}
+		lock.Unlock()
+	}
+	return code
+}
+
+// deleteOne removes one session while its lock is held
+func (c Command) deleteOne(ctx context.Context, e Entry, deleter api.Deleter) bool {
+	if id := e.Data.ConversationID; deleter != nil && id != "" && !api.IsClientSideID(id) {
+		if err := deleter.DeleteConversation(ctx, id); err != nil {
+			fmt.Fprintf(c.Stderr, "Failed to delete conversation %s of %s: %v\n", id, e.Name, err)
+			return false
+		}
+	}
+	if err := Remove(c.Dir, e.Name); err != nil {
+		fmt.Fprintf(c.Stderr, "Failed to remove %s: %v\n", e.Name, err)
+		return false
+	}
+	fmt.Fprintf(c.Stdout, "Removed %s\n", e.Name)
+	return true
+}
+
+// find loads one session by name, reporting problems to Stderr
+func (c Command) find(name string) (Entry, bool) {
+	if !ValidName(name) {
+		fmt.Fprintf(c.Stderr, "Invalid session name: %s\n", name)
+		return Entry{}, false
+	}
+	e := Entry{Name: name, File: File(c.Dir, name)}
+	info, err := os.Stat(e.File)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(c.Stderr, "%s: no such session in %s\n", name, c.Dir)
+		return Entry{}, false
+	}
+	if err == nil {
+		e.ModTime = info.ModTime()
+		e.Data, err = Load(e.File)
+	}
+	if err != nil {
+		fmt.Fprintf(c.Stderr, "%s: %v\n", name, err)
+		return Entry{}, false
+	}
+	return e, true
+}
+
+func (c Command) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.Prog+" sessions "+name, flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	return fs
+}
+
+func (c Command) writeJSON(v interface{}) int {
+	enc := json.NewEncoder(c.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintln(c.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// entryJSON is the --json form of a session
+type entryJSON struct {
+	Name  string `json:"name"`
+	File  string `json:"file"`
+	Error string `json:"load_error,omitempty"`
+	Data
+
+	// The client-side conversation is summarized by size only
+	StateBytes int `json:"conversation_state_bytes,omitempty"`
+}
+
+func newEntryJSON(e Entry) entryJSON {
+	out := entryJSON{Name: e.Name, File: e.File, Data: e.Data, StateBytes: len(e.Data.ConversationState)}
+	out.ConversationState = nil
+	if e.Err != nil {
+		out.Error = e.Err.Error()
+	}
+	if out.UpdatedAt.IsZero() {
+		out.UpdatedAt = e.ModTime.UTC()
+	}
+	return out
+}
+
+// parseFlags parses fs allowing flags before, between and after positional arguments
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// ParseAge parses a Go duration (12h, 30m) or a number of days (7d)
+func ParseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n >= 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, errors.New("invalid --older-than: use a duration like 12h or days like 7d")
+	}
+	return d, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
+
+// writeEvent renders one transcript event for humans
+func writeEvent(w io.Writer, ev Event) {
+	switch ev.Type {
+	case EventSystem:
+		fmt.Fprintf(w, "SYSTEM: %s\n", truncate(ev.Text, 200))
+	case EventFork:
+		fmt.Fprintf(w, "=== Forked from %s  %s ===\n", ev.Name, formatTime(ev.Time))
+	case EventRunStart:
+		fmt.Fprintf(w, "=== Run %d  %s  %s ===\n", ev.Run, formatTime(ev.Time), ev.Name)
+	case EventUser:
+		fmt.Fprintf(w, "USER: %s\n", ev.Text)
+	case EventResponse:
+		line := fmt.Sprintf("--- Iteration %d: %s in %s", ev.Iteration, orDash(ev.ResponseID), time.Duration(ev.DurationMS)*time.Millisecond)
+		if ev.Usage != nil {
+			line += fmt.Sprintf(", %d in (%d cached) / %d out tokens", ev.Usage.InputTokens, ev.Usage.CachedTokens, ev.Usage.OutputTokens)
+		}
+		fmt.Fprintln(w, line)
+	case EventText:
+		fmt.Fprintf(w, "MODEL: %s\n", strings.TrimRight(ev.Text, "\n"))
+	case EventFunctionCall:
+		fmt.Fprintf(w, "CALL %s %s [%s]\n", ev.Name, truncate(ev.Arguments, 200), ev.CallID)
+	case EventToolResult:
+		var result struct {
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		}
+		json.Unmarshal(ev.Result, &result)
+		outcome := "ok"
+		if !result.OK {
+			outcome = "error: " + result.Error
+		}
+		fmt.Fprintf(w, "RESULT %s %s (%dms) %s\n", ev.Name, outcome, ev.DurationMS, truncate(string(ev.Result), 200))
+	case EventRunEnd:
+		line := fmt.Sprintf("=== End of run %d: %s after %d iterations", ev.Run, ev.Status, ev.Iteration)
+		if ev.Text != "" {
+			line += " (" + ev.Text + ")"
+		}
+		fmt.Fprintln(w, line+" ===")
+	default:
+		fmt.Fprintf(w, "%s: %s\n", ev.Type, ev.Text)
+	}
+}
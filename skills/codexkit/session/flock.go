@@ -0,0 +1,39 @@
+//go:build !windows
+
+package session
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// tryLock takes an exclusive flock without blocking; false means it is held elsewhere
+func tryLock(f *os.File) (bool, error) {
+	for {
+		err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+		switch {
+		case err == nil:
+			return true, nil
+		case errors.Is(err, unix.EWOULDBLOCK):
+			return false, nil
+		case errors.Is(err, unix.EINTR):
+			continue
+		}
+		return false, err
+	}
+}
+
+func unlock(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
+
+// removeLocked deletes a held lock's file, then releases the lock
+func removeLocked(l *FileLock, file string) error {
+	err := os.Remove(file)
+	if uerr := l.Unlock(); err == nil {
+		err = uerr
+	}
+	return err
+}
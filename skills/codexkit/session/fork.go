@@ -0,0 +1,223 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"codexkit/api"
+)
+
+// ReplayItems rebuilds conversation items from transcript events: the
+// system prompt of the most recent conversation, then every user message,
+// model message, and function call with its output. Calls without an
+// output (or outputs without a call) are dropped because the API rejects
+// them. Redacted values are replayed as redacted.
+func ReplayItems(events []Event) (systemPrompt string, items []interface{}) {
+	called := map[string]bool{}
+	answered := map[string]bool{}
+	for _, ev := range events {
+		switch ev.Type {
+		case EventFunctionCall:
+			called[ev.CallID] = true
+		case EventToolResult:
+			answered[ev.CallID] = true
+		}
+	}
+
+	emitted := map[string]bool{}
+	for _, ev := range events {
+		switch ev.Type {
+		case EventSystem:
+			systemPrompt = ev.Text
+		case EventUser:
+			items = append(items, map[string]interface{}{"role": "user", "content": ev.Text})
+		case EventText:
+			items = append(items, map[string]interface{}{"role": "assistant", "content": ev.Text})
+		case EventFunctionCall:
+			if !answered[ev.CallID] || emitted["call:"+ev.CallID] {
+				continue
+			}
+			emitted["call:"+ev.CallID] = true
+			items = append(items, map[string]interface{}{
+				"type":      "function_call",
+				"call_id":   ev.CallID,
+				"name":      ev.Name,
+				"arguments": ev.Arguments,
+			})
+		case EventToolResult:
+			if !called[ev.CallID] || emitted["output:"+ev.CallID] {
+				continue
+			}
+			emitted["output:"+ev.CallID] = true
+			items = append(items, map[string]interface{}{
+				"type":    "function_call_output",
+				"call_id": ev.CallID,
+				"output":  string(ev.Result),
+			})
+		}
+	}
+	return systemPrompt, items
+}
+
+// copyFile copies src to dst (missing src is not an error)
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// fork copies session src to dst. With fromResponse the fork is a chain
+// (see api.ChainAdapter) continuing from src's last response; otherwise a
+// new conversation is seeded by replaying src's transcript.
+func (c Command) fork(ctx context.Context, args []string) int {
+	fs := c.flags("fork")
+	fromResponse := fs.Bool("from-response", false, "continue from the last response (previous_response_id) instead of replaying the transcript")
+	names, err := parseFlags(fs, args)
+	if err != nil || len(names) != 2 {
+		c.usage()
+		return 2
+	}
+	srcName, dstName := names[0], names[1]
+	if !ValidName(dstName) {
+		fmt.Fprintf(c.Stderr, "Invalid session name: %s\n", dstName)
+		return 2
+	}
+
+	src, ok := c.find(srcName)
+	if !ok {
+		return 1
+	}
+	if _, err := os.Stat(File(c.Dir, dstName)); err == nil {
+		fmt.Fprintf(c.Stderr, "%s: session already exists\n", dstName)
+		return 1
+	}
+
+	// Hold both locks so neither session changes underneath the fork
+	for _, name := range []string{srcName, dstName} {
+		lock, err := Lock(ctx, c.Dir, name, 0)
+		if err != nil {
+			fmt.Fprintf(c.Stderr, "%s: %v\n", name, err)
+			return 1
+		}
+		defer lock.Unlock()
+	}
+
+	events, err := ReadTranscript(TranscriptFile(c.Dir, srcName))
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintln(c.Stderr, err)
+		return 1
+	}
+
+	dst := src.Data
+	dst.ForkedFrom = srcName
+	dst.CreatedAt = time.Time{}
+	dst.Status = ""
+	dst.Error = ""
+
+	if *fromResponse {
+		if dst.LastResponseID == "" {
+			for _, ev := range events {
+				if ev.Type == EventResponse && ev.ResponseID != "" {
+					dst.LastResponseID = ev.ResponseID
+				}
+			}
+		}
+		if dst.LastResponseID == "" {
+			fmt.Fprintf(c.Stderr, "%s: no response recorded to continue from\n", srcName)
+			return 1
+		}
+		// Pending outputs answer calls in that response, so they carry over
+		id, state, err := api.ForkChain(dst.LastResponseID)
+		if err != nil {
+			fmt.Fprintf(c.Stderr, "Failed to fork %s: %v\n", srcName, err)
+			return 1
+		}
+		dst.ConversationID = id
+		dst.ConversationState = state
+	} else {
+		if len(events) == 0 {
+			fmt.Fprintf(c.Stderr, "%s: no transcript to replay (try --from-response)\n", srcName)
+			return 1
+		}
+		id, state, err := c.replay(ctx, src, events)
+		if err != nil {
+			fmt.Fprintf(c.Stderr, "Failed to fork %s: %v\n", srcName, err)
+			return 1
+		}
+		dst.ConversationID = id
+		dst.ConversationState = state
+		dst.LastResponseID = ""
+		dst.PendingOutputs = nil // Delivered by the replay
+	}
+
+	if err := copyFile(TranscriptFile(c.Dir, srcName), TranscriptFile(c.Dir, dstName)); err != nil {
+		fmt.Fprintf(c.Stderr, "Failed to copy transcript: %v\n", err)
+		return 1
+	}
+	if t, err := OpenTranscript(TranscriptFile(c.Dir, dstName), dst.Runs); err == nil {
+		t.Record(Event{Type: EventFork, Name: srcName})
+		t.Close()
+	}
+	if err := Save(File(c.Dir, dstName), &dst); err != nil {
+		fmt.Fprintf(c.Stderr, "Failed to save %s: %v\n", dstName, err)
+		return 1
+	}
+
+	mode := "replayed transcript into " + dst.ConversationID
+	if *fromResponse {
+		mode = "continues from response " + dst.LastResponseID
+	}
+	fmt.Fprintf(c.Stdout, "Forked %s -> %s (%s)\n", srcName, dstName, mode)
+	return 0
+}
+
+// replay creates a conversation seeded with the transcript's history and
+// returns it with its client-side state, if any
+func (c Command) replay(ctx context.Context, src Entry, events []Event) (string, json.RawMessage, error) {
+	systemPrompt, items := ReplayItems(events)
+	if systemPrompt == "" && c.SystemPrompt != nil {
+		systemPrompt = c.SystemPrompt(src.Name, src.Data)
+	}
+
+	provider, err := c.Connect()
+	if err != nil {
+		return "", nil, err
+	}
+	appender, ok := provider.(api.Appender)
+	if !ok {
+		return "", nil, errors.New("provider cannot add items to a conversation")
+	}
+
+	id, err := provider.CreateConversation(ctx, systemPrompt)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := appender.AddItems(ctx, id, items); err != nil {
+		return "", nil, err
+	}
+	state, err := api.SaveState(provider, id)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, state, nil
+}
@@ -0,0 +1,34 @@
+package session
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestReplayItems(t *testing.T) {
+	events := []Event{
+		{Type: EventSystem, Text: "old prompt"},
+		{Type: EventSystem, Text: "system prompt"},
+		{Type: EventUser, Text: "review auth.go"},
+		{Type: EventResponse, ResponseID: "resp_1"},
+		{Type: EventFunctionCall, CallID: "a", Name: "Read", Arguments: `{"path":"auth.go"}`},
+		{Type: EventFunctionCall, CallID: "lost", Name: "Read", Arguments: `{}`}, // Never answered
+		{Type: EventToolResult, CallID: "a", Name: "Read", Result: json.RawMessage(`{"ok":true}`)},
+		{Type: EventToolResult, CallID: "stray", Name: "Read", Result: json.RawMessage(`{"ok":true}`)},
+		{Type: EventText, Text: "looks fine"},
+		{Type: EventRunEnd, Status: StatusComplete},
+	}
+
+	system, items := ReplayItems(events)
+	if system != "system prompt" {
+		t.Errorf("system prompt = %q", system)
+	}
+	got, _ := json.Marshal(items)
+	want := `[{"content":"review auth.go","role":"user"},` +
+		`{"arguments":"{\"path\":\"auth.go\"}","call_id":"a","name":"Read","type":"function_call"},` +
+		`{"call_id":"a","output":"{\"ok\":true}","type":"function_call_output"},` +
+		`{"content":"looks fine","role":"assistant"}]`
+	if string(got) != want {
+		t.Errorf("items =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entry is a session file found in a sessions directory
+type Entry struct {
+	Name    string
+	File    string
+	ModTime time.Time
+	Data    Data
+	Err     error // Set when the file could not be read or parsed
+}
+
+// Updated returns when the session was last saved, falling back to the
+// file's modification time for legacy files without timestamps
+func (e Entry) Updated() time.Time {
+	if !e.Data.UpdatedAt.IsZero() {
+		return e.Data.UpdatedAt
+	}
+	return e.ModTime
+}
+
+// File returns the session file path for name in dir
+func File(dir, name string) string {
+	return filepath.Join(dir, name+".json")
+}
+
+// List loads every session in dir (not recursive), most recently updated first
+func List(dir string) ([]Entry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, de := range dirEntries {
+		name, ok := strings.CutSuffix(de.Name(), ".json")
+		if !ok || de.IsDir() || !ValidName(name) {
+			continue
+		}
+		e := Entry{Name: name, File: filepath.Join(dir, de.Name())}
+		if info, err := de.Info(); err == nil {
+			e.ModTime = info.ModTime()
+		}
+		e.Data, e.Err = Load(e.File)
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Updated().After(entries[j].Updated())
+	})
+	return entries, nil
+}
+
+// Remove deletes a session's file and transcript. The caller holds the
+// lock, whose file stays behind for RemoveLock.
+func Remove(dir, name string) error {
+	if err := os.Remove(TranscriptFile(dir, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(File(dir, name))
+}
+
+// OrphanLocks returns the names of sessions in dir that left a lock file
+// but no session file
+func OrphanLocks(dir string) ([]string, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, de := range dirEntries {
+		name, ok := strings.CutSuffix(de.Name(), ".lock")
+		if !ok || de.IsDir() || !ValidName(name) {
+			continue
+		}
+		if _, err := os.Stat(File(dir, name)); os.IsNotExist(err) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,128 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned by Lock when another process holds the session
+var ErrLocked = errors.New("session is in use by another process")
+
+// lockPollInterval is how often a waiting Lock retries
+const lockPollInterval = 200 * time.Millisecond
+
+// LockFile returns the lock file path for name in dir. The lock lives in
+// its own file because Save replaces the session file on every write.
+func LockFile(dir, name string) string {
+	return filepath.Join(dir, name+".lock")
+}
+
+// FileLock is an exclusive advisory lock on a session (flock on Unix,
+// LockFileEx on Windows), released by Unlock or when the process exits
+type FileLock struct {
+	f *os.File
+}
+
+// Lock takes the session's lock. If another process holds it, Lock
+// retries until wait has elapsed (0 = fail immediately) and then returns
+// an error wrapping ErrLocked that names the holder's PID.
+func Lock(ctx context.Context, dir, name string, wait time.Duration) (*FileLock, error) {
+	file := LockFile(dir, name)
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if ok {
+			// Prune may have removed the file while this waited on it; the
+			// lock only counts on the file the path names now
+			if isCurrent(f, file) {
+				break
+			}
+			unlock(f)
+			f.Close()
+			if f, err = os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0600); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !time.Now().Before(deadline) {
+			f.Close()
+			return nil, lockedError(file, wait)
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	// Record the holder for the error message other processes print
+	f.Truncate(0)
+	f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return &FileLock{f: f}, nil
+}
+
+// Unlock releases the lock
+func (l *FileLock) Unlock() error {
+	if l == nil {
+		return nil
+	}
+	err := unlock(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// RemoveLock deletes the lock file of a session that no longer exists. It
+// fails with ErrLocked while a run holds the lock; a run that opened the
+// file before it was removed locks a new one instead (see Lock).
+func RemoveLock(ctx context.Context, dir, name string) error {
+	lock, err := Lock(ctx, dir, name, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(File(dir, name)); !os.IsNotExist(err) {
+		lock.Unlock()
+		return fmt.Errorf("session %s still exists", name)
+	}
+	return removeLocked(lock, LockFile(dir, name))
+}
+
+// isCurrent reports whether f still is the file at path
+func isCurrent(f *os.File, path string) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	cur, err := os.Stat(path)
+	return err == nil && os.SameFile(info, cur)
+}
+
+func lockedError(file string, waited time.Duration) error {
+	holder := ""
+	if data, err := os.ReadFile(file); err == nil {
+		if pid := strings.TrimSpace(string(data)); pid != "" {
+			holder = " (PID " + pid + ")"
+		}
+	}
+	if waited > 0 {
+		return fmt.Errorf("%w%s; gave up after waiting %s", ErrLocked, holder, waited)
+	}
+	return fmt.Errorf("%w%s", ErrLocked, holder)
+}
@@ -0,0 +1,127 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockIsExclusive(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	first, err := Lock(ctx, dir, "task-1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Lock(ctx, dir, "task-1", 0)
+	if !errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), "PID "+strconv.Itoa(os.Getpid())) {
+		t.Fatalf("second lock err = %v, want ErrLocked naming the holder", err)
+	}
+
+	// Other sessions are independent
+	other, err := Lock(ctx, dir, "task-2", 0)
+	if err != nil {
+		t.Fatalf("lock on another session: %v", err)
+	}
+	other.Unlock()
+
+	// A waiting caller gets the lock once it is released
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		first.Unlock()
+	}()
+	second, err := Lock(ctx, dir, "task-1", 5*time.Second)
+	if err != nil {
+		t.Fatalf("waiting lock: %v", err)
+	}
+	second.Unlock()
+}
+
+func TestLockWaitRespectsContext(t *testing.T) {
+	dir := t.TempDir()
+	held, err := Lock(context.Background(), dir, "s", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := Lock(ctx, dir, "s", time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context deadline", err)
+	}
+}
+
+func TestRemoveLock(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	for _, name := range []string{"gone", "held", "kept"} {
+		l, err := Lock(ctx, dir, name, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Unlock()
+	}
+	if err := Save(File(dir, "kept"), &Data{}); err != nil {
+		t.Fatal(err)
+	}
+	held, err := Lock(ctx, dir, "held", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Unlock()
+
+	orphans, err := OrphanLocks(dir)
+	if err != nil || strings.Join(orphans, ",") != "gone,held" {
+		t.Fatalf("OrphanLocks = %v, %v; want [gone held]", orphans, err)
+	}
+	if err := RemoveLock(ctx, dir, "gone"); err != nil {
+		t.Errorf("RemoveLock(gone) = %v", err)
+	}
+	if err := RemoveLock(ctx, dir, "held"); !errors.Is(err, ErrLocked) {
+		t.Errorf("RemoveLock(held) = %v, want ErrLocked", err)
+	}
+	if err := RemoveLock(ctx, dir, "kept"); err == nil {
+		t.Error("RemoveLock removed the lock of an existing session")
+	}
+	for name, want := range map[string]bool{"gone": false, "held": true, "kept": true} {
+		if _, err := os.Stat(LockFile(dir, name)); (err == nil) != want {
+			t.Errorf("lock file of %s exists = %v, want %v", name, err == nil, want)
+		}
+	}
+}
+
+func TestLockFollowsRemovedFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows does not remove open files")
+	}
+	dir := t.TempDir()
+	ctx := context.Background()
+	first, err := Lock(ctx, dir, "s", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A waiter holds the old file open when prune removes it
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		removeLocked(first, LockFile(dir, "s"))
+	}()
+	second, err := Lock(ctx, dir, "s", 5*time.Second)
+	if err != nil {
+		t.Fatalf("waiting lock: %v", err)
+	}
+	defer second.Unlock()
+
+	// The waiter must hold the file now at the path, not the removed one
+	if _, err := Lock(ctx, dir, "s", 0); !errors.Is(err, ErrLocked) {
+		t.Errorf("third lock err = %v, want ErrLocked", err)
+	}
+}
@@ -0,0 +1,141 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"codexkit/api"
+)
+
+var safeNameRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Final status of the last run
+const (
+	StatusRunning     = "running" // Run in progress, or the process was killed
+	StatusComplete    = "complete"
+	StatusBlocked     = "blocked"
+	StatusQuestion    = "question" // Model is waiting for an answer ([QUESTION])
+	StatusInterrupted = "interrupted"
+)
+
+// Data stores conversation state and what the session has done so far.
+// Files written before metadata existed hold only conversation_id and
+// load with the remaining fields zero.
+type Data struct {
+	ConversationID string `json:"conversation_id"`
+
+	// LastResponseID is the last response received; sessions fork
+	// --from-response continues from it
+	LastResponseID string `json:"last_response_id,omitempty"`
+	ForkedFrom     string `json:"forked_from,omitempty"` // Session this one was forked from
+
+	// ConversationState is the conversation itself for client-side modes
+	// (chain or local history; see api.StateKeeper)
+	ConversationState json.RawMessage `json:"conversation_state,omitempty"`
+
+	// PendingOutputs are function_call_output items that were produced but
+	// never delivered (run interrupted or stopped); they are sent first on
+	// resume so the conversation has no unanswered function calls
+	PendingOutputs []map[string]interface{} `json:"pending_outputs,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"` // Set by Save
+
+	Kind            string `json:"kind,omitempty"` // "task" or "review"
+	Model           string `json:"model,omitempty"`
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+	RepoRoot        string `json:"repo_root,omitempty"`
+	Prompt          string `json:"prompt,omitempty"` // Task description or review prompt
+	PlanFile        string `json:"plan_file,omitempty"`
+	PlanSHA256      string `json:"plan_sha256,omitempty"`
+
+	Runs           int    `json:"runs"`
+	Iterations     int    `json:"iterations"`      // Across all runs
+	LastIterations int    `json:"last_iterations"` // Of the last run
+	Status         string `json:"status,omitempty"`
+	Error          string `json:"error,omitempty"` // Why the last run was blocked
+
+	// Usage and CostUSD are running totals across all runs of the session
+	Usage   api.Usage `json:"usage"`
+	CostUSD float64   `json:"cost_usd"`
+
+	FilesModified []string `json:"files_modified,omitempty"` // Across all runs, first-touch order
+}
+
+// AddFilesModified appends paths not already recorded
+func (d *Data) AddFilesModified(paths []string) {
+	seen := make(map[string]bool, len(d.FilesModified))
+	for _, p := range d.FilesModified {
+		seen[p] = true
+	}
+	for _, p := range paths {
+		if !seen[p] {
+			seen[p] = true
+			d.FilesModified = append(d.FilesModified, p)
+		}
+	}
+}
+
+// ValidName reports whether name is safe to use as a session file name
+func ValidName(name string) bool {
+	return safeNameRE.MatchString(name)
+}
+
+// Load loads session data; a missing file yields empty data
+func Load(sessionFile string) (Data, error) {
+	var session Data
+	data, err := os.ReadFile(sessionFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return session, nil
+		}
+		return session, err
+	}
+
+	if err := json.Unmarshal(data, &session); err != nil {
+		return Data{}, err
+	}
+
+	return session, nil
+}
+
+// Save stamps the timestamps and atomically saves session data
+func Save(sessionFile string, session *Data) error {
+	now := time.Now().UTC()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	session.UpdatedAt = now
+
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Atomic write: temp file + rename
+	dir := filepath.Dir(sessionFile)
+	tmpFile, err := os.CreateTemp(dir, "session-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+
+	defer func() {
+		tmpFile.Close()
+		os.Remove(tmpPath) // Clean up on error
+	}()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		return err
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	// Atomic rename
+	return os.Rename(tmpPath, sessionFile)
+}
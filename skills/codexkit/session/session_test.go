@@ -0,0 +1,76 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadLegacyFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "old.json")
+	if err := os.WriteFile(file, []byte(`{"conversation_id": "conv_123"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.ConversationID != "conv_123" || sess.Runs != 0 || !sess.CreatedAt.IsZero() {
+		t.Errorf("legacy session = %+v", sess)
+	}
+
+	// First save of a legacy session stamps both timestamps
+	if err := Save(file, &sess); err != nil {
+		t.Fatal(err)
+	}
+	if sess.CreatedAt.IsZero() || !sess.UpdatedAt.Equal(sess.CreatedAt) {
+		t.Errorf("timestamps = %v / %v", sess.CreatedAt, sess.UpdatedAt)
+	}
+}
+
+func TestSaveRoundTripKeepsCreatedAt(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "s.json")
+	sess := Data{ConversationID: "conv_1", Status: StatusQuestion}
+	sess.AddFilesModified([]string{"a.go", "b.go"})
+	sess.AddFilesModified([]string{"b.go", "c.go"})
+	if err := Save(file, &sess); err != nil {
+		t.Fatal(err)
+	}
+	created := sess.CreatedAt
+
+	if err := Save(file, &sess); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.CreatedAt.Equal(created) || loaded.UpdatedAt.Before(created) {
+		t.Errorf("timestamps = %v / %v, created %v", loaded.CreatedAt, loaded.UpdatedAt, created)
+	}
+	if !reflect.DeepEqual(loaded.FilesModified, []string{"a.go", "b.go", "c.go"}) || loaded.Status != StatusQuestion {
+		t.Errorf("loaded = %+v", loaded)
+	}
+
+	// No temp files are left behind
+	entries, _ := os.ReadDir(filepath.Dir(file))
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want 1", len(entries))
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	for in, want := range map[string]time.Duration{"7d": 7 * 24 * time.Hour, "12h": 12 * time.Hour, "0d": 0} {
+		if got, err := ParseAge(in); err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "soon", "-1h", "xd"} {
+		if _, err := ParseAge(in); err == nil {
+			t.Errorf("ParseAge(%q) should fail", in)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	summaryTextLimit  = 1500 // Bytes of a run's final model text kept
+	summaryToolLimit  = 30   // Distinct tool targets listed per run
+	summaryTruncation = "..."
+)
+
+// Summarize compacts a transcript into a short plain-text history: per
+// run, the prompt, the outcome, the tools used and the model's final text.
+// Runs that never got a response are skipped. When the result exceeds
+// limit bytes the oldest runs are dropped first. Returns "" for an empty
+// transcript.
+func Summarize(events []Event, limit int) string {
+	type runSummary struct {
+		run       int
+		prompt    string
+		status    string
+		responses int
+		tools     []string
+		calls     int
+		text      string
+	}
+
+	var runs []*runSummary
+	byRun := map[int]*runSummary{}
+	seen := map[int]map[string]bool{}
+	for _, ev := range events {
+		r := byRun[ev.Run]
+		if r == nil {
+			r = &runSummary{run: ev.Run}
+			byRun[ev.Run] = r
+			seen[ev.Run] = map[string]bool{}
+			runs = append(runs, r)
+		}
+		switch ev.Type {
+		case EventUser:
+			r.prompt = ev.Text
+		case EventResponse:
+			r.responses++
+		case EventText:
+			r.text = ev.Text
+		case EventFunctionCall:
+			r.calls++
+			target := ev.Name
+			if arg := toolTarget(ev.Arguments); arg != "" {
+				target += " " + arg
+			}
+			if !seen[ev.Run][target] {
+				seen[ev.Run][target] = true
+				r.tools = append(r.tools, target)
+			}
+		case EventRunEnd:
+			r.status = ev.Status
+		}
+	}
+
+	var sections []string
+	for _, r := range runs {
+		if r.responses == 0 {
+			continue
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "## Run %d", r.run)
+		if r.status != "" {
+			fmt.Fprintf(&b, " (%s)", r.status)
+		}
+		b.WriteString("\n")
+		if r.prompt != "" {
+			fmt.Fprintf(&b, "Prompt: %s\n", Clip(r.prompt, summaryTextLimit))
+		}
+		if r.calls > 0 {
+			tools := r.tools
+			more := ""
+			if len(tools) > summaryToolLimit {
+				more = fmt.Sprintf(", +%d more", len(tools)-summaryToolLimit)
+				tools = tools[:summaryToolLimit]
+			}
+			fmt.Fprintf(&b, "Tools (%d calls): %s%s\n", r.calls, strings.Join(tools, ", "), more)
+		}
+		if text := strings.TrimSpace(r.text); text != "" {
+			fmt.Fprintf(&b, "Result:\n%s\n", Clip(text, summaryTextLimit))
+		}
+		sections = append(sections, b.String())
+	}
+
+	// Keep the most recent runs that fit
+	size, first := 0, len(sections)
+	for first > 0 && size+len(sections[first-1]) <= limit {
+		first--
+		size += len(sections[first])
+	}
+	if first == len(sections) && first > 0 {
+		// Even the last run alone is too long: keep a cut-down version
+		first--
+		sections[first] = Clip(sections[first], limit)
+	}
+
+	var out strings.Builder
+	if first > 0 {
+		fmt.Fprintf(&out, "(%d earlier runs omitted)\n\n", first)
+	}
+	out.WriteString(strings.Join(sections[first:], "\n"))
+	return out.String()
+}
+
+// toolTarget returns the file or pattern a tool call was aimed at
+func toolTarget(arguments string) string {
+	var args map[string]interface{}
+	if json.Unmarshal([]byte(arguments), &args) != nil {
+		return ""
+	}
+	for _, key := range []string{"path", "pattern", "query"} {
+		if v, ok := args[key].(string); ok && v != "" {
+			return Clip(v, 200)
+		}
+	}
+	return ""
+}
+
+// Clip shortens s to at most n bytes on a rune boundary, marking the cut
+func Clip(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := max(n-len(summaryTruncation), 0)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + summaryTruncation
+}
@@ -0,0 +1,55 @@
+package session
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	events := []Event{
+		{Run: 1, Type: EventSystem, Text: "system prompt"},
+		{Run: 1, Type: EventUser, Text: "Add a login page"},
+		{Run: 1, Type: EventResponse, ResponseID: "resp_1"},
+		{Run: 1, Type: EventFunctionCall, Name: "Read", Arguments: `{"path":"app.go"}`},
+		{Run: 1, Type: EventFunctionCall, Name: "Read", Arguments: `{"path":"app.go"}`},
+		{Run: 1, Type: EventFunctionCall, Name: "Grep", Arguments: `{"query":"router"}`},
+		{Run: 1, Type: EventResponse, ResponseID: "resp_2"},
+		{Run: 1, Type: EventText, Text: "[QUESTION] Which auth provider?"},
+		{Run: 1, Type: EventRunEnd, Status: StatusQuestion},
+		// A run that failed before any response adds nothing
+		{Run: 2, Type: EventUser, Text: "Use OAuth"},
+		{Run: 2, Type: EventRunEnd, Status: StatusBlocked},
+	}
+
+	got := Summarize(events, 4096)
+	want := "## Run 1 (question)\n" +
+		"Prompt: Add a login page\n" +
+		"Tools (3 calls): Read app.go, Grep router\n" +
+		"Result:\n[QUESTION] Which auth provider?\n"
+	if got != want {
+		t.Errorf("Summarize =\n%s\nwant\n%s", got, want)
+	}
+	if Summarize(nil, 4096) != "" {
+		t.Error("empty transcript should summarize to nothing")
+	}
+}
+
+func TestSummarizeKeepsRecentRuns(t *testing.T) {
+	var events []Event
+	for run := 1; run <= 5; run++ {
+		events = append(events,
+			Event{Run: run, Type: EventUser, Text: strings.Repeat("x", 100)},
+			Event{Run: run, Type: EventResponse},
+			Event{Run: run, Type: EventRunEnd, Status: StatusComplete},
+		)
+	}
+
+	got := Summarize(events, 300)
+	if !strings.HasPrefix(got, "(3 earlier runs omitted)") || !strings.Contains(got, "## Run 4") || !strings.Contains(got, "## Run 5") {
+		t.Errorf("Summarize =\n%s", got)
+	}
+
+	if got := Summarize(events[12:], 50); len(got) > 50 || !strings.HasSuffix(got, "...") {
+		t.Errorf("oversized single run = %q", got)
+	}
+}
@@ -0,0 +1,140 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"codexkit/api"
+)
+
+// Transcript event types
+const (
+	EventSystem       = "system"        // Text = system prompt of a new conversation
+	EventFork         = "fork"          // Name = session forked from
+	EventRunStart     = "run_start"     // Name = model
+	EventUser         = "user"          // Text = prompt
+	EventResponse     = "response"      // ResponseID, DurationMS, Usage
+	EventText         = "text"          // Text = model output
+	EventFunctionCall = "function_call" // CallID, Name, Arguments
+	EventToolResult   = "tool_result"   // CallID, Name, Result, DurationMS
+	EventRunEnd       = "run_end"       // Status, Iteration = iterations, Usage, Text = error
+)
+
+// Event is one line of a session transcript. Text, Arguments and Result
+// are redacted by the recorder before they are written.
+type Event struct {
+	Time       time.Time       `json:"time"`
+	Run        int             `json:"run"`
+	Type       string          `json:"type"`
+	Iteration  int             `json:"iteration,omitempty"`
+	Text       string          `json:"text,omitempty"`
+	CallID     string          `json:"call_id,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	Arguments  string          `json:"arguments,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	ResponseID string          `json:"response_id,omitempty"`
+	DurationMS int64           `json:"duration_ms,omitempty"`
+	Usage      *api.Usage      `json:"usage,omitempty"`
+	Status     string          `json:"status,omitempty"`
+}
+
+// TranscriptFile returns the JSONL transcript path for name in dir
+func TranscriptFile(dir, name string) string {
+	return filepath.Join(dir, name+".jsonl")
+}
+
+// Transcript appends events to a session's JSONL transcript. A nil
+// *Transcript records nothing, so callers need no checks.
+type Transcript struct {
+	mu  sync.Mutex
+	f   *os.File
+	run int
+	err error // First write error, reported by Close
+}
+
+// OpenTranscript opens (or creates) a transcript for appending; events are
+// tagged with run
+func OpenTranscript(file string, run int) (*Transcript, error) {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Transcript{f: f, run: run}, nil
+}
+
+// Record appends ev, stamping its time and run. Write errors do not stop
+// the run; the first one is returned by Close.
+func (t *Transcript) Record(ev Event) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.f == nil {
+		return
+	}
+
+	ev.Time = time.Now().UTC()
+	ev.Run = t.run
+	if len(ev.Result) > 0 && !json.Valid(ev.Result) {
+		ev.Result, _ = json.Marshal(string(ev.Result))
+	}
+	line, err := json.Marshal(ev)
+	if err == nil {
+		_, err = t.f.Write(append(line, '\n'))
+	}
+	if err != nil && t.err == nil {
+		t.err = err
+	}
+}
+
+// Close closes the file and returns the first error seen. Closing twice
+// is harmless; events recorded after Close are dropped.
+func (t *Transcript) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.f == nil {
+		return t.err
+	}
+	if err := t.f.Close(); err != nil && t.err == nil {
+		t.err = err
+	}
+	t.f = nil
+	return t.err
+}
+
+// ReadTranscript loads all events of a transcript; a line cut short by a
+// crash is skipped
+func ReadTranscript(file string) ([]Event, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		events = append(events, ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return events, fmt.Errorf("reading %s: %w", file, err)
+	}
+	return events, nil
+}